@@ -1,10 +1,15 @@
 package api
 
 import (
+	"encoding/base64"
+	"errors"
+	"multi-chain-payment-gateway/internal/chains"
 	"multi-chain-payment-gateway/internal/services"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type PaymentHandler struct {
@@ -72,6 +77,111 @@ func (h *PaymentHandler) GetPaymentStatus(c *gin.Context) {
 	})
 }
 
+// GetGaslessTypedData returns the EIP-712 typed data the payer at ?from=
+// signs to pay an option without gas.
+func (h *PaymentHandler) GetGaslessTypedData(c *gin.Context) {
+	optionID, err := strconv.ParseUint(c.Param("option_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid option ID"})
+		return
+	}
+	from := c.Query("from")
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+		return
+	}
+
+	typedData, err := h.paymentService.GaslessTypedData(c.Param("id"), uint(optionID), from)
+	if err != nil {
+		gaslessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, typedData)
+}
+
+// SubmitGasless relays a signed authorization paying an option.
+func (h *PaymentHandler) SubmitGasless(c *gin.Context) {
+	optionID, err := strconv.ParseUint(c.Param("option_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid option ID"})
+		return
+	}
+	var req services.GaslessPayment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.paymentService.SubmitGasless(c.Param("id"), uint(optionID), req)
+	if err != nil {
+		gaslessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, tx)
+}
+
+func gaslessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment option not found"})
+	case errors.Is(err, services.ErrPaymentNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGaslessUnavailable),
+		errors.Is(err, chains.ErrAuthorizationUnsupported),
+		errors.Is(err, chains.ErrInvalidAuthorization):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetTransactionRequest answers the first request of a Solana Pay
+// transaction request with the label and icon the wallet shows.
+func (h *PaymentHandler) GetTransactionRequest(c *gin.Context) {
+	c.JSON(http.StatusOK, h.paymentService.TransactionRequestLabel())
+}
+
+// CreateTransactionRequest returns the unsigned transaction with which the
+// wallet's account pays an option.
+func (h *PaymentHandler) CreateTransactionRequest(c *gin.Context) {
+	optionID, err := strconv.ParseUint(c.Param("option_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid option ID"})
+		return
+	}
+	var req struct {
+		Account string `json:"account" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.paymentService.PaymentTransaction(c.Param("id"), uint(optionID), req.Account)
+	if err != nil {
+		transactionRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transaction": base64.StdEncoding.EncodeToString(tx)})
+}
+
+func transactionRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment option not found"})
+	case errors.Is(err, services.ErrPaymentNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTransactionRequestUnavailable),
+		errors.Is(err, chains.ErrInvalidPayer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func (h *PaymentHandler) ServeWidget(c *gin.Context) {
 	paymentID := c.Param("id")
 
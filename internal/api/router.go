@@ -43,6 +43,10 @@ func NewRouter(paymentService *services.PaymentService, webhookService *services
 		api.POST("/payments", paymentHandler.CreatePayment)
 		api.GET("/payments/:id", paymentHandler.GetPayment)
 		api.GET("/payments/:id/status", paymentHandler.GetPaymentStatus)
+		api.GET("/payments/:id/options/:option_id/gasless", paymentHandler.GetGaslessTypedData)
+		api.POST("/payments/:id/options/:option_id/gasless", paymentHandler.SubmitGasless)
+		api.GET("/payments/:id/options/:option_id/transaction", paymentHandler.GetTransactionRequest)
+		api.POST("/payments/:id/options/:option_id/transaction", paymentHandler.CreateTransactionRequest)
 	}
 
 	// Widget routes
@@ -0,0 +1,146 @@
+package chains
+
+import (
+	"context"
+	"errors"
+	"multi-chain-payment-gateway/internal/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrTransactionDropped is returned when a previously detected transaction is
+// no longer part of the canonical chain, typically after a reorg.
+var ErrTransactionDropped = errors.New("transaction dropped from chain")
+
+// ChainAdapter is everything the gateway needs to accept payments on one
+// network. Each chain lives in its own package and is registered in a
+// Registry at startup.
+type ChainAdapter interface {
+	// Chain identifies the network the adapter serves.
+	Chain() models.Chain
+
+	// NewAddress returns a fresh deposit address for a payment.
+	NewAddress(req AddressRequest) (*Wallet, error)
+
+	// ValidateAddress reports whether address is well-formed for the chain.
+	ValidateAddress(address string) error
+
+	// Tokens lists the assets accepted on the chain.
+	Tokens() []Token
+
+	// Sync lets the adapter catch up on chain state for the deposits that
+	// are currently awaited. Adapters that query per address may no-op.
+	Sync(ctx context.Context, deposits []Deposit) error
+
+	// CheckPayment returns a transfer that covers the deposit, or nil if
+	// none has arrived yet.
+	CheckPayment(ctx context.Context, deposit Deposit) (*models.Transaction, error)
+
+	// Confirmations reports how deep tx is buried. It returns
+	// ErrTransactionDropped if tx has left the chain, and may update tx's
+	// block fields if it was re-included elsewhere.
+	Confirmations(ctx context.Context, tx *models.Transaction) (int, error)
+
+	// RequiredConfirmations is the depth at which transactions are final.
+	RequiredConfirmations() int
+}
+
+// Poller is implemented by adapters that should be checked for payments at
+// their own pace rather than the gateway's default monitoring interval.
+type Poller interface {
+	PollInterval() time.Duration
+}
+
+// PublicDeriver is implemented by adapters that can derive deposit
+// addresses from public key material alone, such as an xpub or a
+// contract factory, or that hand out the merchant's own address.
+// DerivesPublicly reports whether they are configured to, in which case
+// NewAddress never returns a private key.
+type PublicDeriver interface {
+	DerivesPublicly() bool
+}
+
+// InvoiceIssuer is implemented by adapters that bill each payment with an
+// invoice for the exact amount, such as Lightning, instead of handing out
+// deposit addresses. NewAddress is not used for them.
+type InvoiceIssuer interface {
+	NewInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error)
+}
+
+// InvoiceRequest describes the invoice to issue for a payment option.
+type InvoiceRequest struct {
+	PaymentID string
+	Token     Token
+	Amount    decimal.Decimal
+	ExpiresAt time.Time
+}
+
+// Invoice is an issued invoice.
+type Invoice struct {
+	// Request is what the payer pays, e.g. a BOLT11 payment request. It is
+	// stored where deposit addresses are.
+	Request string
+
+	// Reference identifies the invoice to the adapter, e.g. its payment hash.
+	Reference string
+}
+
+// Token describes an asset accepted on a chain.
+type Token struct {
+	Token    models.TokenType
+	Symbol   string
+	Decimals int
+
+	// Contract is the ERC-20 contract, SPL mint or jetton master of the
+	// token. It is empty for the chain's native asset.
+	Contract string
+}
+
+// AddressRequest carries the payment context an address is created for.
+// A payment gets one address per chain, shared by all its tokens there.
+type AddressRequest struct {
+	PaymentID string
+}
+
+// Wallet is a deposit address handed out for a payment.
+type Wallet struct {
+	Address    string
+	PrivateKey string // set for random addresses, moved to the keystore
+	Chain      models.Chain
+
+	// Set for addresses derived from an extended public key.
+	DerivationIndex uint32
+	DerivationPath  string
+
+	// Salt is set for counterfactual contract addresses, such as CREATE2
+	// forwarders, and is needed to deploy them.
+	Salt string
+
+	// Reference identifies the payment to the chain, as a memo or an extra
+	// account key. The payer has to include it when the address is the
+	// merchant's own, shared by all payments, for the payment to be told
+	// apart.
+	Reference string
+}
+
+// Deposit is a transfer the gateway is waiting for.
+type Deposit struct {
+	Address string
+	Token   Token
+	Amount  decimal.Decimal
+
+	// Reference is the invoice reference for invoice-based options, or the
+	// payment reference, e.g. a Solana Pay reference key.
+	Reference string
+}
+
+// FindToken returns the adapter's metadata for token.
+func FindToken(adapter ChainAdapter, token models.TokenType) (Token, bool) {
+	for _, t := range adapter.Tokens() {
+		if t.Token == token {
+			return t, true
+		}
+	}
+	return Token{}, false
+}
@@ -0,0 +1,46 @@
+package chains
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	// ErrAuthorizationUnsupported is returned for tokens that cannot be
+	// paid with transfer authorizations.
+	ErrAuthorizationUnsupported = errors.New("token does not support transfer authorizations")
+
+	// ErrInvalidAuthorization is returned for a transfer authorization that
+	// is wrongly signed or that the token contract would reject.
+	ErrInvalidAuthorization = errors.New("invalid transfer authorization")
+)
+
+// AuthorizationRelayer is implemented by adapters that can take token
+// payments as signed transfer authorizations, such as EIP-3009's
+// transferWithAuthorization, and submit them from a relayer account that
+// pays the fees, so the payer needs no native balance.
+type AuthorizationRelayer interface {
+	// AuthorizationTypedData returns the structured data the payer signs
+	// for auth, e.g. an EIP-712 typed data object, ready to be encoded as
+	// JSON. It fails if auth's token does not support authorizations.
+	AuthorizationTypedData(ctx context.Context, auth TransferAuthorization) (interface{}, error)
+
+	// RelayAuthorization checks signature against auth and broadcasts the
+	// transfer from relayerKey's account, returning the transaction hash.
+	RelayAuthorization(ctx context.Context, relayerKey string, auth TransferAuthorization, signature string) (string, error)
+}
+
+// TransferAuthorization is a transfer of Token that From allows anyone to
+// submit between ValidAfter and ValidBefore. Nonce is a random 32-byte hex
+// string that keeps it from being submitted twice.
+type TransferAuthorization struct {
+	Token       Token
+	From        string
+	To          string
+	Value       *big.Int // base units
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	Nonce       string
+}
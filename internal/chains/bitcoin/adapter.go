@@ -0,0 +1,253 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/shopspring/decimal"
+)
+
+// satoshiDecimals is the number of decimals of a bitcoin.
+const satoshiDecimals = 8
+
+// Config configures the Bitcoin adapter.
+type Config struct {
+	EsploraURL    string
+	XPub          string // BIP84 account xpub/zpub; random keys if empty
+	Network       string // "mainnet", "testnet" or "regtest"
+	Tokens        []chains.Token
+	Confirmations int
+}
+
+// Adapter accepts BTC payments to native SegWit (P2WPKH) addresses.
+type Adapter struct {
+	config  Config
+	store   chains.Store
+	params  *chaincfg.Params
+	esplora *esploraClient
+	xpub    *hdkeychain.ExtendedKey
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	params, err := networkParams(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Adapter{
+		config:  cfg,
+		store:   store,
+		params:  params,
+		esplora: newEsploraClient(cfg.EsploraURL),
+	}
+
+	if cfg.XPub != "" {
+		xpub, err := chains.ParseAccountXPub(cfg.XPub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BITCOIN_XPUB: %w", err)
+		}
+		a.xpub = xpub
+	}
+
+	return a, nil
+}
+
+func networkParams(network string) (*chaincfg.Params, error) {
+	switch network {
+	case "", "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	default:
+		return nil, fmt.Errorf("unknown BITCOIN_NETWORK %q", network)
+	}
+}
+
+// accountPath is the BIP84 account the xpub is expected to be exported at.
+func (a *Adapter) accountPath() string {
+	if a.params.Net == chaincfg.MainNetParams.Net {
+		return "m/84'/0'/0'"
+	}
+	return "m/84'/1'/0'"
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return models.ChainBitcoin
+}
+
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	if a.xpub != nil {
+		return a.deriveAddress()
+	}
+
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	address, err := a.witnessAddress(privateKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	wif, err := btcutil.NewWIF(privateKey, a.params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:    address,
+		PrivateKey: wif.String(),
+		Chain:      models.ChainBitcoin,
+	}, nil
+}
+
+// DerivesPublicly reports whether addresses come from BITCOIN_XPUB rather
+// than random keys.
+func (a *Adapter) DerivesPublicly() bool {
+	return a.xpub != nil
+}
+
+func (a *Adapter) deriveAddress() (*chains.Wallet, error) {
+	index, err := a.store.NextIndex(string(models.ChainBitcoin))
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := chains.DeriveChild(a.xpub, index)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	address, err := a.witnessAddress(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:         address,
+		Chain:           models.ChainBitcoin,
+		DerivationIndex: index,
+		DerivationPath:  fmt.Sprintf("%s/0/%d", a.accountPath(), index),
+	}, nil
+}
+
+// witnessAddress returns the bech32 P2WPKH address of publicKey.
+func (a *Adapter) witnessAddress(publicKey *btcec.PublicKey) (string, error) {
+	hash := btcutil.Hash160(publicKey.SerializeCompressed())
+	address, err := btcutil.NewAddressWitnessPubKeyHash(hash, a.params)
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	decoded, err := btcutil.DecodeAddress(address, a.params)
+	if err != nil {
+		return fmt.Errorf("invalid Bitcoin address %q: %w", address, err)
+	}
+	if !decoded.IsForNet(a.params) {
+		return fmt.Errorf("Bitcoin address %q is not for %s", address, a.params.Name)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	return nil
+}
+
+// CheckPayment looks through the deposit address's mempool and recent
+// transactions for one whose outputs pay it at least the expected amount.
+// Unconfirmed transactions are reported too; the payment only counts once
+// they reach the required confirmations.
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	if deposit.Token.Token != models.TokenNative {
+		return nil, nil
+	}
+
+	txs, err := a.esplora.addressTransactions(ctx, deposit.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := deposit.Amount.Shift(satoshiDecimals)
+	for _, tx := range txs {
+		var received int64
+		for _, out := range tx.Vout {
+			if out.Address == deposit.Address {
+				received += out.Value
+			}
+		}
+
+		amount := decimal.NewFromInt(received)
+		if received == 0 || amount.LessThan(expected) {
+			continue
+		}
+
+		var from string
+		if len(tx.Vin) > 0 && tx.Vin[0].Prevout != nil {
+			from = tx.Vin[0].Prevout.Address
+		}
+
+		return &models.Transaction{
+			Chain:       models.ChainBitcoin,
+			TxHash:      tx.TxID,
+			FromAddress: from,
+			ToAddress:   deposit.Address,
+			Amount:      amount.Shift(-satoshiDecimals),
+			Token:       models.TokenNative,
+			BlockNumber: tx.Status.BlockHeight,
+			BlockHash:   tx.Status.BlockHash,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// Confirmations returns how many blocks deep tx is, or zero while it is in
+// the mempool. A transaction the backend no longer knows was replaced or
+// evicted and is reported as dropped.
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	status, err := a.esplora.txStatus(ctx, tx.TxHash)
+	if errors.Is(err, errNotFound) {
+		return 0, chains.ErrTransactionDropped
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !status.Confirmed {
+		return 0, nil
+	}
+
+	tx.BlockNumber = status.BlockHeight
+	tx.BlockHash = status.BlockHash
+
+	tip, err := a.esplora.tipHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if tip < tx.BlockNumber {
+		return 0, nil
+	}
+	return int(tip-tx.BlockNumber) + 1, nil
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return a.config.Confirmations
+}
@@ -0,0 +1,264 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// Account keys at m/84'/0'/0' of the BIP39 test mnemonic "abandon abandon
+// ... about", in the BIP84 test vectors' zpub form and as a plain xpub.
+const (
+	testZPub = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+	testXPub = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+)
+
+var btc = chains.Token{Token: models.TokenNative, Symbol: "BTC", Decimals: satoshiDecimals}
+
+func TestDeriveAddress(t *testing.T) {
+	// The BIP84 test vectors' first two receive addresses
+	want := []string{
+		"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		"bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+	}
+
+	for _, xpub := range []string{testZPub, testXPub} {
+		a, err := New(Config{XPub: xpub}, chaintest.NewStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index, address := range want {
+			wallet, err := a.NewAddress(chains.AddressRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wallet.Address != address || wallet.DerivationIndex != uint32(index) || wallet.PrivateKey != "" {
+				t.Errorf("NewAddress() = %+v, want %s", wallet, address)
+			}
+			if err := a.ValidateAddress(wallet.Address); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		network string
+		address string
+		valid   bool
+	}{
+		{"mainnet", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", true},
+		{"mainnet", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyv", false}, // checksum
+		{"mainnet", "tb1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", false},
+		{"mainnet", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", true},
+		{"testnet", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", false},
+		{"mainnet", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", false},
+	}
+	for _, tt := range tests {
+		a, err := New(Config{Network: tt.network}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.ValidateAddress(tt.address); (err == nil) != tt.valid {
+			t.Errorf("ValidateAddress(%q) on %s = %v, want valid %v", tt.address, tt.network, err, tt.valid)
+		}
+	}
+}
+
+func TestRandomAddress(t *testing.T) {
+	a, err := New(Config{Network: "regtest"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := a.NewAddress(chains.AddressRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.PrivateKey == "" || wallet.DerivationPath != "" {
+		t.Errorf("NewAddress() = %+v, want a random key", wallet)
+	}
+	if err := a.ValidateAddress(wallet.Address); err != nil {
+		t.Error(err)
+	}
+}
+
+// newFakeEsplora returns an adapter on a stand-in Esplora API answering the
+// paths in responses; a missing path is answered 404 like Esplora does.
+func newFakeEsplora(t *testing.T, responses map[string]interface{}) *Adapter {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	a, err := New(Config{EsploraURL: server.URL + "/", Tokens: []chains.Token{btc}, Confirmations: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+const (
+	depositAddress = "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"
+	changeAddress  = "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"
+	payer          = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+)
+
+// payment spends a payer output into outputs of the given satoshis.
+func payment(txid string, confirmed bool, outputs ...txOutput) transaction {
+	tx := transaction{TxID: txid, Vout: outputs}
+	tx.Vin = append(tx.Vin, struct {
+		Prevout *txOutput `json:"prevout"`
+	}{Prevout: &txOutput{Address: payer, Value: 1_000_000_000}})
+	if confirmed {
+		tx.Status = txStatus{Confirmed: true, BlockHeight: 800_000, BlockHash: "00000000000000000002"}
+	}
+	return tx
+}
+
+func TestCheckPayment(t *testing.T) {
+	tests := []struct {
+		name       string
+		txs        []transaction
+		want       string
+		wantAmount string
+		wantHeight uint64
+	}{
+		{
+			name:       "confirmed payment",
+			txs:        []transaction{payment("a1", true, txOutput{depositAddress, 150_000}, txOutput{changeAddress, 1_000})},
+			want:       "a1",
+			wantAmount: "0.0015",
+			wantHeight: 800_000,
+		},
+		{
+			name:       "payment in the mempool",
+			txs:        []transaction{payment("a1", false, txOutput{depositAddress, 150_000})},
+			want:       "a1",
+			wantAmount: "0.0015",
+		},
+		{
+			name:       "payment split across outputs",
+			txs:        []transaction{payment("a1", true, txOutput{depositAddress, 100_000}, txOutput{depositAddress, 50_000})},
+			want:       "a1",
+			wantAmount: "0.0015",
+			wantHeight: 800_000,
+		},
+		{
+			name: "underpayment before a payment",
+			txs: []transaction{
+				payment("a1", false, txOutput{depositAddress, 149_999}),
+				payment("a2", true, txOutput{depositAddress, 200_000}),
+			},
+			want:       "a2",
+			wantAmount: "0.002",
+			wantHeight: 800_000,
+		},
+		{
+			name: "paid elsewhere",
+			txs:  []transaction{payment("a1", true, txOutput{changeAddress, 150_000})},
+		},
+		{
+			name: "no transactions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := tt.txs
+			if txs == nil {
+				txs = []transaction{}
+			}
+			a := newFakeEsplora(t, map[string]interface{}{"/address/" + depositAddress + "/txs": txs})
+
+			tx, err := a.CheckPayment(context.Background(), chains.Deposit{
+				Address: depositAddress,
+				Token:   btc,
+				Amount:  decimal.RequireFromString("0.0015"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if tx != nil {
+					t.Fatalf("CheckPayment() = %+v, want none", tx)
+				}
+				return
+			}
+			if tx == nil || tx.TxHash != tt.want || tx.FromAddress != payer || tx.ToAddress != depositAddress {
+				t.Fatalf("CheckPayment() = %+v, want %s from %s", tx, tt.want, payer)
+			}
+			if !tx.Amount.Equal(decimal.RequireFromString(tt.wantAmount)) || tx.BlockNumber != tt.wantHeight {
+				t.Errorf("CheckPayment() = %s at %d, want %s at %d", tx.Amount, tx.BlockNumber, tt.wantAmount, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  interface{}
+		want    int
+		wantErr error
+	}{
+		{
+			name:   "in the mempool",
+			status: txStatus{},
+		},
+		{
+			name:   "in the tip block",
+			status: txStatus{Confirmed: true, BlockHeight: 800_005, BlockHash: "b5"},
+			want:   1,
+		},
+		{
+			name:   "buried",
+			status: txStatus{Confirmed: true, BlockHeight: 800_000, BlockHash: "b0"},
+			want:   6,
+		},
+		{
+			name:   "ahead of a lagging tip",
+			status: txStatus{Confirmed: true, BlockHeight: 800_006, BlockHash: "b6"},
+		},
+		{
+			name:    "replaced or evicted",
+			wantErr: chains.ErrTransactionDropped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]interface{}{"/blocks/tip/height": 800_005}
+			if tt.status != nil {
+				responses["/tx/a1/status"] = tt.status
+			}
+			a := newFakeEsplora(t, responses)
+
+			tx := &models.Transaction{TxHash: "a1"}
+			got, err := a.Confirmations(context.Background(), tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirmations() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Confirmations() = %d, want %d", got, tt.want)
+			}
+			if status, ok := tt.status.(txStatus); ok && status.Confirmed && tx.BlockHash != status.BlockHash {
+				t.Errorf("block hash = %q, want %q", tx.BlockHash, status.BlockHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned for resources the Esplora backend doesn't know,
+// such as a transaction that was replaced or evicted from the mempool.
+var errNotFound = errors.New("not found")
+
+// esploraClient is a minimal client of an Esplora-compatible REST API, as
+// served by Blockstream's and mempool.space's explorers or a local electrs.
+type esploraClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newEsploraClient(baseURL string) *esploraClient {
+	return &esploraClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *esploraClient) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("esplora returned status %d for %s", resp.StatusCode, path)
+	}
+	return body, nil
+}
+
+type txStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight uint64 `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+}
+
+type txOutput struct {
+	Address string `json:"scriptpubkey_address"`
+	Value   int64  `json:"value"`
+}
+
+type transaction struct {
+	TxID   string   `json:"txid"`
+	Status txStatus `json:"status"`
+	Vin    []struct {
+		Prevout *txOutput `json:"prevout"`
+	} `json:"vin"`
+	Vout []txOutput `json:"vout"`
+}
+
+// addressTransactions returns the mempool transactions of address followed
+// by its most recent confirmed ones.
+func (c *esploraClient) addressTransactions(ctx context.Context, address string) ([]transaction, error) {
+	body, err := c.get(ctx, "/address/"+address+"/txs")
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []transaction
+	if err := json.Unmarshal(body, &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (c *esploraClient) txStatus(ctx context.Context, txid string) (*txStatus, error) {
+	body, err := c.get(ctx, "/tx/"+txid+"/status")
+	if err != nil {
+		return nil, err
+	}
+
+	var status txStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *esploraClient) tipHeight(ctx context.Context) (uint64, error) {
+	body, err := c.get(ctx, "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(body)), 10, 64)
+}
@@ -0,0 +1,73 @@
+// Package chaintest provides helpers for testing chain adapters.
+package chaintest
+
+import (
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+)
+
+// Store is an in-memory chains.Store behaving like the database-backed
+// services.ChainStore.
+type Store struct {
+	mu      sync.Mutex
+	indexes map[string]uint32
+	cursors map[models.Chain]chains.Cursor
+	nonces  map[string]uint64 // next nonce, keyed by chain and address
+}
+
+func NewStore() *Store {
+	return &Store{
+		indexes: make(map[string]uint32),
+		cursors: make(map[models.Chain]chains.Cursor),
+		nonces:  make(map[string]uint64),
+	}
+}
+
+func (s *Store) NextIndex(scope string) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexes[scope]
+	s.indexes[scope]++
+	return index, nil
+}
+
+func (s *Store) LoadCursor(chain models.Chain) (*chains.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cursor, ok := s.cursors[chain]
+	if !ok {
+		return nil, nil
+	}
+	return &cursor, nil
+}
+
+func (s *Store) SaveCursor(chain models.Chain, cursor chains.Cursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[chain] = cursor
+	return nil
+}
+
+func (s *Store) ReserveNonce(chain models.Chain, address string, minimum uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%s", chain, address)
+	nonce := s.nonces[key]
+	if nonce < minimum {
+		nonce = minimum
+	}
+	s.nonces[key] = nonce + 1
+	return nonce, nil
+}
+
+func (s *Store) ReleaseNonce(chain models.Chain, address string, nonce uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%s", chain, address)
+	if s.nonces[key] == nonce+1 {
+		s.nonces[key] = nonce
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// accountPath is the BIP44 account the configured xpub is expected to be
+// exported at; deposit addresses live on its external chain.
+const accountPath = "m/44'/60'/0'"
+
+// derivationScope is the derivation counter shared by all EVM networks.
+// They derive from the same account, so sharing the counter keeps an index
+// from being handed out twice, and funds sent on the wrong network remain
+// recoverable with the same key.
+const derivationScope = "ethereum"
+
+// Config configures an adapter for one EVM network.
+type Config struct {
+	Chain         models.Chain
+	Name          string // human-readable network name used in logs and errors
+	ChainID       int64
+	RPCURL        string
+	XPub          string
+	Tokens        []chains.Token // the native asset plus ERC-20 tokens
+	Confirmations int
+	PollInterval  time.Duration
+
+	// Optional CREATE2 forwarder factory and the keccak256 hash of the
+	// forwarder init code it deploys; deposit addresses are then
+	// counterfactual forwarders instead of keys.
+	ForwarderFactory      string
+	ForwarderInitCodeHash string
+}
+
+// Adapter accepts native and ERC-20 stablecoin payments on an EVM network.
+type Adapter struct {
+	config  Config
+	store   chains.Store
+	client  *ethclient.Client
+	scanner *Scanner
+	txs     *TxManager
+	xpub    *hdkeychain.ExtendedKey
+
+	factory      *common.Address
+	initCodeHash []byte
+
+	// EIP-712 domains of EIP-3009 token contracts, read on first use
+	domainsMu sync.Mutex
+	domains   map[string]apitypes.TypedDataDomain
+
+	// chainChecked is set once the RPC endpoint's chain ID has been
+	// verified against the configured one.
+	chainChecked bool
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	a := &Adapter{
+		config:  cfg,
+		store:   store,
+		domains: make(map[string]apitypes.TypedDataDomain),
+	}
+
+	// Derive deposit addresses from the xpub if one is configured
+	if cfg.XPub != "" {
+		xpub, err := chains.ParseAccountXPub(cfg.XPub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ETHEREUM_XPUB: %w", err)
+		}
+		a.xpub = xpub
+	}
+
+	if cfg.ForwarderFactory != "" || cfg.ForwarderInitCodeHash != "" {
+		if err := a.setForwarderFactory(cfg.ForwarderFactory, cfg.ForwarderInitCodeHash); err != nil {
+			return nil, fmt.Errorf("invalid %s forwarder settings: %w", cfg.Name, err)
+		}
+	}
+
+	// Initialize the RPC client if an URL is provided
+	if cfg.RPCURL != "" {
+		if client, err := ethclient.Dial(cfg.RPCURL); err == nil {
+			a.client = client
+			a.scanner = NewScanner(client, store, cfg.Chain, cfg.Name, cfg.Tokens, cfg.Confirmations)
+			a.txs = NewTxManager(client, store, cfg.Chain, cfg.Name, cfg.ChainID)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return a.config.Chain
+}
+
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	if a.factory != nil {
+		return a.forwarderAddress(req), nil
+	}
+	if a.xpub != nil {
+		return a.deriveAddress()
+	}
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey).Hex(),
+		PrivateKey: fmt.Sprintf("%x", crypto.FromECDSA(privateKey)),
+		Chain:      a.config.Chain,
+	}, nil
+}
+
+// DerivesPublicly reports whether ETHEREUM_XPUB or a forwarder factory is
+// set.
+func (a *Adapter) DerivesPublicly() bool {
+	return a.xpub != nil || a.factory != nil
+}
+
+func (a *Adapter) deriveAddress() (*chains.Wallet, error) {
+	index, err := a.store.NextIndex(derivationScope)
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := chains.DeriveChild(a.xpub, index)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:         crypto.PubkeyToAddress(*publicKey.ToECDSA()).Hex(),
+		Chain:           a.config.Chain,
+		DerivationIndex: index,
+		DerivationPath:  fmt.Sprintf("%s/0/%d", accountPath, index),
+	}, nil
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid %s address: %s", a.config.Name, address)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	if a.scanner == nil {
+		return nil
+	}
+	if err := a.checkChainID(ctx); err != nil {
+		return err
+	}
+
+	addresses := make([]string, 0, len(deposits))
+	for _, deposit := range deposits {
+		addresses = append(addresses, deposit.Address)
+	}
+	return a.scanner.Scan(ctx, addresses)
+}
+
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	if a.scanner == nil {
+		return nil, nil
+	}
+	return a.scanner.TakeDeposit(deposit.Address, deposit.Token.Token, deposit.Amount), nil
+}
+
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	if a.scanner == nil {
+		return 0, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+	return a.scanner.Confirmations(ctx, tx)
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return a.config.Confirmations
+}
+
+// PollInterval is how often the network is checked for new payments,
+// about once per block.
+func (a *Adapter) PollInterval() time.Duration {
+	return a.config.PollInterval
+}
+
+// checkChainID refuses to scan an RPC endpoint serving a different network
+// than configured, which would credit payments seen on the wrong chain.
+func (a *Adapter) checkChainID(ctx context.Context) error {
+	if a.chainChecked || a.config.ChainID == 0 {
+		return nil
+	}
+
+	chainID, err := a.client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	if chainID.Int64() != a.config.ChainID {
+		return fmt.Errorf("%s RPC serves chain ID %s, expected %d", a.config.Name, chainID, a.config.ChainID)
+	}
+
+	a.chainChecked = true
+	return nil
+}
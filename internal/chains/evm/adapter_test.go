@@ -0,0 +1,267 @@
+package evm
+
+import (
+	"context"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+// Account xpub at m/44'/60'/0' of the BIP39 test mnemonic "abandon
+// abandon ... about".
+const testXPub = "xpub6DCoCpSuQZB2jawqnGMEPS63ePKWkwWPH4TU45Q7LPXWuNd8TMtVxRrgjtEshuqpK3mdhaWHPFsBngh5GFZaM6si3yZdUsT8ddYM3PwnATt"
+
+func TestDeriveAddress(t *testing.T) {
+	store := chaintest.NewStore()
+	ethereum, err := New(Config{Chain: models.ChainEthereum, Name: "Ethereum", XPub: testXPub}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := New(Config{Chain: models.ChainBase, Name: "Base", XPub: testXPub}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// EVM networks share one derivation counter
+	tests := []struct {
+		adapter *Adapter
+		address string
+		path    string
+	}{
+		{ethereum, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", "m/44'/60'/0'/0/0"},
+		{base, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", "m/44'/60'/0'/0/1"},
+	}
+	for _, tt := range tests {
+		wallet, err := tt.adapter.NewAddress(chains.AddressRequest{PaymentID: "p"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wallet.Address != tt.address || wallet.DerivationPath != tt.path || wallet.PrivateKey != "" {
+			t.Errorf("NewAddress() = %+v, want %s at %s", wallet, tt.address, tt.path)
+		}
+	}
+}
+
+func TestRandomAddress(t *testing.T) {
+	a, err := New(Config{Chain: models.ChainEthereum, Name: "Ethereum"}, chaintest.NewStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := a.NewAddress(chains.AddressRequest{PaymentID: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, err := a.AddressOf(wallet.PrivateKey)
+	if err != nil || owner != wallet.Address {
+		t.Errorf("AddressOf(key) = %s, %v, want %s", owner, err, wallet.Address)
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94", true},
+		{"0x9858effd232b4033e47d90003d41ec34ecaeda94", true},
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEda", false},
+		{"TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH", false},
+	}
+	a, _ := New(Config{Name: "Ethereum"}, chaintest.NewStore())
+	for _, tt := range tests {
+		if err := a.ValidateAddress(tt.address); (err == nil) != tt.valid {
+			t.Errorf("ValidateAddress(%q) = %v, want valid %v", tt.address, err, tt.valid)
+		}
+	}
+}
+
+func TestForwarderAddress(t *testing.T) {
+	factory := "0x00000000000000000000000000000000000000fa"
+	initCodeHash := crypto.Keccak256Hash([]byte("forwarder init code"))
+	a, err := New(Config{
+		Chain:                 models.ChainEthereum,
+		Name:                  "Ethereum",
+		ForwarderFactory:      factory,
+		ForwarderInitCodeHash: initCodeHash.Hex(),
+	}, chaintest.NewStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet, err := a.NewAddress(chains.AddressRequest{PaymentID: "payment-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := crypto.Keccak256Hash([]byte("payment-1"))
+	want := crypto.CreateAddress2(common.HexToAddress(factory), salt, initCodeHash.Bytes())
+	if wallet.Address != want.Hex() || wallet.Salt != salt.Hex() {
+		t.Errorf("NewAddress() = %+v, want %s with salt %s", wallet, want.Hex(), salt.Hex())
+	}
+	if !a.DerivesPublicly() {
+		t.Error("forwarder addresses need no keys")
+	}
+
+	for _, invalid := range []Config{
+		{ForwarderFactory: "factory", ForwarderInitCodeHash: initCodeHash.Hex()},
+		{ForwarderFactory: factory, ForwarderInitCodeHash: "0x1234"},
+	} {
+		if _, err := New(invalid, chaintest.NewStore()); err == nil {
+			t.Errorf("New(%+v) accepted invalid forwarder settings", invalid)
+		}
+	}
+}
+
+func TestFlushCalldata(t *testing.T) {
+	factoryABI, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"flush","inputs":[{"name":"token","type":"address"},{"name":"salts","type":"bytes32[]"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	salts := []common.Hash{crypto.Keccak256Hash([]byte("a")), crypto.Keccak256Hash([]byte("b"))}
+
+	tests := []struct {
+		name  string
+		token chains.Token
+		want  common.Address
+	}{
+		{"native", chains.Token{Token: models.TokenNative}, common.Address{}},
+		{"token", usdc, common.HexToAddress(usdc.Contract)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := flushCalldata(tt.token, []string{salts[0].Hex(), salts[1].Hex()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := factoryABI.Pack("flush", tt.want, [][32]byte{salts[0], salts[1]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hexutil.Encode(got) != hexutil.Encode(want) {
+				t.Errorf("flushCalldata() = %x, want %x", got, want)
+			}
+		})
+	}
+
+	if _, err := flushCalldata(usdc, []string{"0x1234"}); err == nil {
+		t.Error("accepted a short salt")
+	}
+}
+
+func TestPaymentURI(t *testing.T) {
+	recipient := "0x9858effd232b4033e47d90003d41ec34ecaeda94"
+	tests := []struct {
+		name    string
+		chainID int64
+		token   chains.Token
+		amount  string
+		want    string
+	}{
+		{
+			name:    "native",
+			chainID: 1,
+			token:   chains.Token{Token: models.TokenNative, Decimals: 18},
+			amount:  "0.0125",
+			want:    "ethereum:0x9858EfFD232B4033E47d90003D41EC34EcaEda94@1?value=12500000000000000",
+		},
+		{
+			name:    "token rounded up",
+			chainID: 8453,
+			token:   usdc,
+			amount:  "12.3456789",
+			want:    "ethereum:0x00000000000000000000000000000000000000C0@8453/transfer?address=0x9858EfFD232B4033E47d90003D41EC34EcaEda94&uint256=12345679",
+		},
+		{
+			name:   "no chain ID",
+			token:  chains.Token{Token: models.TokenNative, Decimals: 18},
+			amount: "1",
+			want:   "ethereum:0x9858EfFD232B4033E47d90003D41EC34EcaEda94?value=1000000000000000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(Config{Name: "Ethereum", ChainID: tt.chainID}, chaintest.NewStore())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := a.PaymentURI(chains.PaymentRequest{
+				Address: recipient,
+				Token:   tt.token,
+				Amount:  decimal.RequireFromString(tt.amount),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("PaymentURI() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPayment(t *testing.T) {
+	tests := []struct {
+		name    string
+		chainID string
+		wantErr bool
+	}{
+		{"matching chain", "0x1", false},
+		{"wrong chain", "0x89", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, _ := newFakeNode(t)
+			chain := newFakeChain(t, node, 12)
+			node.result("eth_chainId", tt.chainID)
+			chain.transfer(12, depositAddress, 1e18, 1)
+			chain.tokenTransfer(12, usdc, depositAddress, 5_000_000)
+
+			store := chaintest.NewStore()
+			store.SaveCursor(models.ChainEthereum, chains.Cursor{Height: 11})
+			a, err := New(Config{
+				Chain:   models.ChainEthereum,
+				Name:    "Ethereum",
+				ChainID: 1,
+				RPCURL:  node.url,
+				Tokens:  testTokens,
+			}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deposits := []chains.Deposit{
+				{Address: depositAddress.Hex(), Token: testTokens[0], Amount: decimal.NewFromInt(1)},
+				{Address: depositAddress.Hex(), Token: usdc, Amount: decimal.NewFromInt(5)},
+			}
+			err = a.Sync(context.Background(), deposits)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("scanned a node serving another chain")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, deposit := range deposits {
+				tx, err := a.CheckPayment(context.Background(), deposit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tx == nil || tx.Token != deposit.Token.Token || !tx.Amount.Equal(deposit.Amount) || tx.BlockNumber != 12 {
+					t.Errorf("CheckPayment(%s) = %+v", deposit.Token.Token, tx)
+				}
+			}
+		})
+	}
+}
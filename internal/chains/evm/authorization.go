@@ -0,0 +1,242 @@
+package evm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// eip3009ABI covers the parts of an EIP-3009 token, such as USDC's
+// FiatToken, used to take gasless payments.
+const eip3009ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"version","stateMutability":"view","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"DOMAIN_SEPARATOR","stateMutability":"view","inputs":[],"outputs":[{"type":"bytes32"}]},
+	{"type":"function","name":"transferWithAuthorization","stateMutability":"nonpayable","inputs":[
+		{"name":"from","type":"address"},
+		{"name":"to","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"validAfter","type":"uint256"},
+		{"name":"validBefore","type":"uint256"},
+		{"name":"nonce","type":"bytes32"},
+		{"name":"v","type":"uint8"},
+		{"name":"r","type":"bytes32"},
+		{"name":"s","type":"bytes32"}
+	],"outputs":[]}
+]`
+
+var eip3009 = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(eip3009ABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// authorizationTypes are the EIP-712 types of an EIP-3009 transfer.
+var authorizationTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"TransferWithAuthorization": {
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "validAfter", Type: "uint256"},
+		{Name: "validBefore", Type: "uint256"},
+		{Name: "nonce", Type: "bytes32"},
+	},
+}
+
+// Gas headroom over a relayed transfer's estimate, in percent
+const relayGasMargin = 20
+
+// AuthorizationTypedData returns the EIP-712 TransferWithAuthorization
+// typed data of auth, to be signed with eth_signTypedData_v4. Its map form
+// leaves unused domain fields out, which some wallets insist on.
+func (a *Adapter) AuthorizationTypedData(ctx context.Context, auth chains.TransferAuthorization) (interface{}, error) {
+	typed, err := a.authorizationTypedData(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	return typed.Map(), nil
+}
+
+func (a *Adapter) authorizationTypedData(ctx context.Context, auth chains.TransferAuthorization) (*apitypes.TypedData, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+	if auth.Token.Token == models.TokenNative {
+		return nil, fmt.Errorf("%w: %s", chains.ErrAuthorizationUnsupported, auth.Token.Symbol)
+	}
+	if !common.IsHexAddress(auth.From) || !common.IsHexAddress(auth.To) {
+		return nil, fmt.Errorf("%w: malformed address", chains.ErrInvalidAuthorization)
+	}
+	nonce, err := hexutil.Decode(auth.Nonce)
+	if err != nil || len(nonce) != common.HashLength {
+		return nil, fmt.Errorf("%w: nonce must be 32 bytes of hex", chains.ErrInvalidAuthorization)
+	}
+	if err := a.checkChainID(ctx); err != nil {
+		return nil, err
+	}
+
+	domain, err := a.tokenDomain(ctx, auth.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apitypes.TypedData{
+		Types:       authorizationTypes,
+		PrimaryType: "TransferWithAuthorization",
+		Domain:      domain,
+		Message: apitypes.TypedDataMessage{
+			"from":        common.HexToAddress(auth.From).Hex(),
+			"to":          common.HexToAddress(auth.To).Hex(),
+			"value":       auth.Value.String(),
+			"validAfter":  unixTime(auth.ValidAfter).String(),
+			"validBefore": unixTime(auth.ValidBefore).String(),
+			"nonce":       hexutil.Encode(nonce),
+		},
+	}, nil
+}
+
+// tokenDomain reads the EIP-712 domain of token's contract. Tokens without
+// EIP-3009 support are told apart by their missing or different
+// DOMAIN_SEPARATOR.
+func (a *Adapter) tokenDomain(ctx context.Context, token chains.Token) (apitypes.TypedDataDomain, error) {
+	a.domainsMu.Lock()
+	domain, ok := a.domains[token.Contract]
+	a.domainsMu.Unlock()
+	if ok {
+		return domain, nil
+	}
+
+	unsupported := fmt.Errorf("%w: %s on %s", chains.ErrAuthorizationUnsupported, token.Symbol, a.config.Name)
+	var name, version string
+	var separator [32]byte
+	for method, out := range map[string]interface{}{"name": &name, "version": &version, "DOMAIN_SEPARATOR": &separator} {
+		if err := a.callToken(ctx, token, method, out); err != nil {
+			return domain, fmt.Errorf("%w (%s: %v)", unsupported, method, err)
+		}
+	}
+
+	domain = apitypes.TypedDataDomain{
+		Name:              name,
+		Version:           version,
+		ChainId:           math.NewHexOrDecimal256(a.config.ChainID),
+		VerifyingContract: common.HexToAddress(token.Contract).Hex(),
+	}
+	typed := apitypes.TypedData{Types: authorizationTypes, Domain: domain}
+	expected, err := typed.HashStruct("EIP712Domain", domain.Map())
+	if err != nil {
+		return domain, err
+	}
+	if !bytes.Equal(expected, separator[:]) {
+		return domain, unsupported
+	}
+
+	a.domainsMu.Lock()
+	a.domains[token.Contract] = domain
+	a.domainsMu.Unlock()
+	return domain, nil
+}
+
+// callToken calls a view method of token's contract and unpacks its only
+// return value into out.
+func (a *Adapter) callToken(ctx context.Context, token chains.Token, method string, out interface{}) error {
+	data, err := eip3009.Pack(method)
+	if err != nil {
+		return err
+	}
+	contract := common.HexToAddress(token.Contract)
+	result, err := a.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return err
+	}
+	return eip3009.UnpackIntoInterface(out, method, result)
+}
+
+// RelayAuthorization verifies that auth.From signed auth and submits it
+// with transferWithAuthorization, paid for by relayerKey. Authorizations
+// the contract would reject fail gas estimation and are never broadcast.
+func (a *Adapter) RelayAuthorization(ctx context.Context, relayerKey string, auth chains.TransferAuthorization, signature string) (string, error) {
+	typed, err := a.authorizationTypedData(ctx, auth)
+	if err != nil {
+		return "", err
+	}
+	key, err := parsePrivateKey(relayerKey)
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(*typed)
+	if err != nil {
+		return "", err
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return "", fmt.Errorf("%w: signature must be 65 bytes of hex", chains.ErrInvalidAuthorization)
+	}
+	// Wallets return v as 27 or 28, recovery expects 0 or 1
+	v := sig[crypto.RecoveryIDOffset]
+	if v >= 27 {
+		v -= 27
+	}
+	recoverable := append(append([]byte{}, sig[:crypto.RecoveryIDOffset]...), v)
+	signer, err := crypto.SigToPub(hash, recoverable)
+	if err != nil || crypto.PubkeyToAddress(*signer) != common.HexToAddress(auth.From) {
+		return "", fmt.Errorf("%w: not signed by %s", chains.ErrInvalidAuthorization, auth.From)
+	}
+
+	data, err := eip3009.Pack("transferWithAuthorization",
+		common.HexToAddress(auth.From),
+		common.HexToAddress(auth.To),
+		auth.Value,
+		unixTime(auth.ValidAfter),
+		unixTime(auth.ValidBefore),
+		common.HexToHash(auth.Nonce),
+		v+27,
+		common.BytesToHash(sig[:32]),
+		common.BytesToHash(sig[32:64]),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	contract := common.HexToAddress(auth.Token.Contract)
+	gas, err := a.client.EstimateGas(ctx, ethereum.CallMsg{
+		From: crypto.PubkeyToAddress(key.PublicKey),
+		To:   &contract,
+		Data: data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", chains.ErrInvalidAuthorization, err)
+	}
+	gas += gas * relayGasMargin / 100
+
+	return a.txs.Send(ctx, key, contract, new(big.Int), data, gas, nil)
+}
+
+// unixTime encodes t as a uint256 timestamp; the zero time is 0.
+func unixTime(t time.Time) *big.Int {
+	if t.IsZero() {
+		return new(big.Int)
+	}
+	return big.NewInt(t.Unix())
+}
@@ -0,0 +1,121 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Deposit addresses can be forwarder contracts that a factory deploys with
+// CREATE2, so their address is known before anything is deployed:
+//
+//	keccak256(0xff ++ factory ++ salt ++ keccak256(initCode))[12:]
+//
+// Each forwarder sends whatever it holds to the treasury fixed in its init
+// code. The factory is expected to implement
+//
+//	function flush(address token, bytes32[] calldata salts)
+//
+// deploying the forwarder of each salt if needed and making it forward its
+// balance of token, or of the native asset for the zero address.
+var factoryFlush = crypto.Keccak256([]byte("flush(address,bytes32[])"))[:4]
+
+// Gas headroom over the factory call's estimate, in percent
+const flushGasMargin = 20
+
+func (a *Adapter) setForwarderFactory(factory, initCodeHash string) error {
+	if !common.IsHexAddress(factory) {
+		return fmt.Errorf("factory %q is not an address", factory)
+	}
+	hash, err := hexutil.Decode(initCodeHash)
+	if err != nil || len(hash) != common.HashLength {
+		return fmt.Errorf("init code hash %q is not a 32-byte hex string", initCodeHash)
+	}
+
+	address := common.HexToAddress(factory)
+	a.factory = &address
+	a.initCodeHash = hash
+	return nil
+}
+
+// forwarderSalt is derived from the payment, so an address can be
+// recomputed from the payment ID alone.
+func forwarderSalt(req chains.AddressRequest) common.Hash {
+	return crypto.Keccak256Hash([]byte(req.PaymentID))
+}
+
+func (a *Adapter) forwarderAddress(req chains.AddressRequest) *chains.Wallet {
+	salt := forwarderSalt(req)
+	return &chains.Wallet{
+		Address: crypto.CreateAddress2(*a.factory, salt, a.initCodeHash).Hex(),
+		Chain:   a.config.Chain,
+		Salt:    salt.Hex(),
+	}
+}
+
+// FlushForwarders calls the factory's flush for token with salts.
+func (a *Adapter) FlushForwarders(ctx context.Context, privateKey string, token chains.Token, salts []string, record *models.OutgoingTransaction) (string, error) {
+	if a.client == nil {
+		return "", fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+	if a.factory == nil {
+		return "", fmt.Errorf("no %s forwarder factory configured", a.config.Name)
+	}
+	if len(salts) == 0 {
+		return "", errors.New("no forwarders to flush")
+	}
+	if err := a.checkChainID(ctx); err != nil {
+		return "", err
+	}
+
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := flushCalldata(token, salts)
+	if err != nil {
+		return "", err
+	}
+
+	gas, err := a.client.EstimateGas(ctx, ethereum.CallMsg{
+		From: crypto.PubkeyToAddress(key.PublicKey),
+		To:   a.factory,
+		Data: data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("estimating forwarder flush: %w", err)
+	}
+	gas += gas * flushGasMargin / 100
+
+	return a.txs.Send(ctx, key, *a.factory, new(big.Int), data, gas, record)
+}
+
+// flushCalldata encodes flush(token, salts). The dynamic array goes after
+// the two head words, at offset 64.
+func flushCalldata(token chains.Token, salts []string) ([]byte, error) {
+	var tokenAddress common.Address
+	if token.Token != models.TokenNative {
+		tokenAddress = common.HexToAddress(token.Contract)
+	}
+
+	data := append(append([]byte{}, factoryFlush...), common.LeftPadBytes(tokenAddress.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(64).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(salts))).Bytes(), 32)...)
+	for _, salt := range salts {
+		raw, err := hexutil.Decode(salt)
+		if err != nil || len(raw) != common.HashLength {
+			return nil, fmt.Errorf("invalid forwarder salt %q", salt)
+		}
+		data = append(data, raw...)
+	}
+	return data, nil
+}
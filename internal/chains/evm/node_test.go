@@ -0,0 +1,123 @@
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// fakeNode is a stand-in JSON-RPC endpoint answering the methods a test
+// registers.
+type fakeNode struct {
+	t        *testing.T
+	url      string
+	mu       sync.Mutex
+	handlers map[string]func(params []json.RawMessage) (interface{}, error)
+	calls    map[string]int
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *ethclient.Client) {
+	t.Helper()
+	node := &fakeNode{
+		t:        t,
+		handlers: make(map[string]func([]json.RawMessage) (interface{}, error)),
+		calls:    make(map[string]int),
+	}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	node.url = server.URL
+
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return node, client
+}
+
+func (n *fakeNode) handle(method string, fn func(params []json.RawMessage) (interface{}, error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[method] = fn
+}
+
+// result answers method with a fixed result.
+func (n *fakeNode) result(method string, result interface{}) {
+	n.handle(method, func([]json.RawMessage) (interface{}, error) { return result, nil })
+}
+
+func (n *fakeNode) callCount(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	handler, ok := n.handlers[req.Method]
+	n.calls[req.Method]++
+	n.mu.Unlock()
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if !ok {
+		n.t.Errorf("unexpected RPC call %s", req.Method)
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	} else if result, err := handler(req.Params); err != nil {
+		response["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+	} else {
+		response["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// header returns a block header as eth_getBlockByNumber serves it.
+func header(number uint64, baseFee int64) *types.Header {
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: new(big.Int),
+		BaseFee:    big.NewInt(baseFee),
+		GasLimit:   30000000,
+	}
+}
+
+// stringParam decodes the string parameter at index i.
+func stringParam(t *testing.T, params []json.RawMessage, i int) string {
+	t.Helper()
+	var s string
+	if err := json.Unmarshal(params[i], &s); err != nil {
+		t.Fatalf("parameter %d: %v", i, err)
+	}
+	return s
+}
+
+// rawTransaction decodes the transaction of an eth_sendRawTransaction call.
+func rawTransaction(t *testing.T, params []json.RawMessage) *types.Transaction {
+	t.Helper()
+	raw, err := hexutil.Decode(stringParam(t, params, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	return &tx
+}
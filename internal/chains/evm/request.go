@@ -0,0 +1,40 @@
+package evm
+
+import (
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PaymentURI returns the EIP-681 payment request for req, a plain value
+// transfer for the native asset or a call of the token's transfer:
+//
+//	ethereum:<recipient>@<chainId>?value=<wei>
+//	ethereum:<token>@<chainId>/transfer?address=<recipient>&uint256=<amount>
+//
+// Amounts are in base units, rounded up so paying them exactly is enough.
+// The chain ID is left out if none is configured, which wallets take as
+// the network they are on.
+func (a *Adapter) PaymentURI(req chains.PaymentRequest) (string, error) {
+	if !common.IsHexAddress(req.Address) {
+		return "", fmt.Errorf("invalid %s address %q", a.config.Name, req.Address)
+	}
+	recipient := common.HexToAddress(req.Address).Hex()
+	amount := req.Amount.Shift(int32(req.Token.Decimals)).Ceil().BigInt()
+
+	var chainID string
+	if a.config.ChainID != 0 {
+		chainID = fmt.Sprintf("@%d", a.config.ChainID)
+	}
+
+	if req.Token.Token == models.TokenNative {
+		return fmt.Sprintf("ethereum:%s%s?value=%s", recipient, chainID, amount), nil
+	}
+	if !common.IsHexAddress(req.Token.Contract) {
+		return "", fmt.Errorf("no contract configured for %s on %s", req.Token.Symbol, a.config.Name)
+	}
+	token := common.HexToAddress(req.Token.Contract).Hex()
+	return fmt.Sprintf("ethereum:%s%s/transfer?address=%s&uint256=%s", token, chainID, recipient, amount), nil
+}
@@ -0,0 +1,405 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/shopspring/decimal"
+)
+
+// maxBlocksPerScan bounds how far a single Scan call walks, so catching up
+// after downtime doesn't stall the monitoring loop.
+const maxBlocksPerScan = 100
+
+// erc20TransferTopic is keccak256("Transfer(address,address,uint256)").
+var erc20TransferTopic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// Scanner walks new blocks and records native transfers and ERC-20
+// Transfer events sent to watched deposit addresses. Only top-level
+// transactions are seen for the native asset; value moved by
+// contract-internal calls is not detected.
+//
+// Detected transfers are only held in memory until TakeDeposit hands them
+// out, so the persisted cursor never moves past the block of a transfer
+// that has not been taken yet. After a restart those blocks are scanned
+// again and the transfer is detected anew.
+type Scanner struct {
+	client *ethclient.Client
+	store  chains.Store
+	chain  models.Chain
+	name   string
+	native chains.Token
+	tokens map[common.Address]chains.Token
+
+	// reorgDepth is how many blocks the cursor rewinds when the last scanned
+	// block is no longer canonical.
+	reorgDepth uint64
+
+	// position is the last scanned block, which may be ahead of the
+	// persisted cursor
+	position *chains.Cursor
+
+	mu       sync.Mutex
+	deposits map[string][]*models.Transaction // keyed by lowercase recipient
+}
+
+// rpcBlock and rpcTransaction decode only the fields the scanner needs, so
+// blocks containing transaction types unknown to go-ethereum still parse.
+type rpcBlock struct {
+	Number       hexutil.Uint64   `json:"number"`
+	Hash         common.Hash      `json:"hash"`
+	Transactions []rpcTransaction `json:"transactions"`
+}
+
+type rpcTransaction struct {
+	Hash  common.Hash     `json:"hash"`
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+}
+
+type rpcReceipt struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Status      hexutil.Uint64 `json:"status"`
+}
+
+func NewScanner(client *ethclient.Client, store chains.Store, chain models.Chain, name string, tokens []chains.Token, reorgDepth int) *Scanner {
+	s := &Scanner{
+		client:     client,
+		store:      store,
+		chain:      chain,
+		name:       name,
+		native:     chains.Token{Token: models.TokenNative, Decimals: 18},
+		tokens:     make(map[common.Address]chains.Token),
+		reorgDepth: uint64(reorgDepth),
+		deposits:   make(map[string][]*models.Transaction),
+	}
+
+	for _, token := range tokens {
+		if token.Contract != "" {
+			s.tokens[common.HexToAddress(token.Contract)] = token
+		} else if token.Token == models.TokenNative {
+			s.native = token
+		}
+	}
+	return s
+}
+
+// Scan walks blocks after the persisted cursor up to the chain head and
+// records transfers to any of the watched addresses.
+func (s *Scanner) Scan(ctx context.Context, watched []string) error {
+	watch := make(map[string]bool, len(watched))
+	for _, address := range watched {
+		watch[strings.ToLower(address)] = true
+	}
+	s.pruneDeposits(watch)
+
+	head, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	cursor := s.position
+	if cursor == nil {
+		cursor, err = s.store.LoadCursor(s.chain)
+		if err != nil {
+			return err
+		}
+	}
+	if cursor == nil {
+		// First run: start from the current head rather than genesis
+		cursor = &chains.Cursor{}
+		if head > 0 {
+			cursor.Height = head - 1
+		}
+	}
+
+	if err := s.checkCursor(ctx, cursor); err != nil {
+		return err
+	}
+
+	to := head
+	if to > cursor.Height+maxBlocksPerScan {
+		to = cursor.Height + maxBlocksPerScan
+	}
+
+	from := cursor.Height + 1
+	if from > to {
+		// Transfers may have been taken since the last scan
+		s.position = cursor
+		return s.store.SaveCursor(s.chain, s.checkpoint())
+	}
+
+	var last *rpcBlock
+	if len(watch) > 0 {
+		for number := from; number <= to; number++ {
+			block, err := s.scanBlock(ctx, number, watch)
+			if err != nil {
+				return err
+			}
+			last = block
+		}
+		if err := s.scanTokenTransfers(ctx, from, to, watch); err != nil {
+			return err
+		}
+	} else {
+		block, err := s.fetchBlock(ctx, to, false)
+		if err != nil {
+			return err
+		}
+		last = block
+	}
+
+	s.position = &chains.Cursor{Height: to, Hash: last.Hash.Hex()}
+	return s.store.SaveCursor(s.chain, s.checkpoint())
+}
+
+// checkpoint returns the cursor to persist: the scan position, held back to
+// just before the earliest block with a transfer that was not taken yet.
+func (s *Scanner) checkpoint() chains.Cursor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoint := *s.position
+	for _, deposits := range s.deposits {
+		for _, deposit := range deposits {
+			if deposit.BlockNumber <= checkpoint.Height {
+				// The hash of the block before is unknown; the reorg check
+				// is skipped for a cursor without one
+				checkpoint = chains.Cursor{Height: deposit.BlockNumber - 1}
+			}
+		}
+	}
+	return checkpoint
+}
+
+// checkCursor rewinds the cursor when the block it points at has been
+// replaced by a reorg, so transfers in the new canonical blocks are rescanned.
+func (s *Scanner) checkCursor(ctx context.Context, cursor *chains.Cursor) error {
+	if cursor.Hash == "" {
+		return nil
+	}
+
+	block, err := s.fetchBlock(ctx, cursor.Height, false)
+	if err != nil {
+		return err
+	}
+	if block.Hash.Hex() == cursor.Hash {
+		return nil
+	}
+
+	rewind := s.reorgDepth
+	if rewind > cursor.Height {
+		rewind = cursor.Height
+	}
+	log.Printf("%s reorg detected at block %d, rescanning the last %d blocks", s.name, cursor.Height, rewind)
+	cursor.Height -= rewind
+	cursor.Hash = ""
+	return nil
+}
+
+func (s *Scanner) fetchBlock(ctx context.Context, number uint64, withTransactions bool) (*rpcBlock, error) {
+	var block *rpcBlock
+	err := s.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), withTransactions)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return block, nil
+}
+
+func (s *Scanner) scanBlock(ctx context.Context, number uint64, watch map[string]bool) (*rpcBlock, error) {
+	block, err := s.fetchBlock(ctx, number, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.To == nil || tx.Value == nil || tx.Value.ToInt().Sign() == 0 {
+			continue
+		}
+
+		if !watch[strings.ToLower(tx.To.Hex())] {
+			continue
+		}
+
+		// A reverted transaction moves no value
+		var receipt *rpcReceipt
+		if err := s.client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", tx.Hash); err != nil {
+			return nil, err
+		}
+		if receipt == nil || receipt.Status == 0 {
+			continue
+		}
+
+		deposit := &models.Transaction{
+			Chain:       s.chain,
+			TxHash:      tx.Hash.Hex(),
+			FromAddress: tx.From.Hex(),
+			ToAddress:   tx.To.Hex(),
+			Amount:      decimal.NewFromBigInt(tx.Value.ToInt(), -int32(s.native.Decimals)),
+			Token:       models.TokenNative,
+			BlockNumber: uint64(block.Number),
+			BlockHash:   block.Hash.Hex(),
+		}
+
+		s.record(deposit)
+	}
+
+	return block, nil
+}
+
+// scanTokenTransfers matches Transfer events of the configured token
+// contracts whose recipient is a watched address.
+func (s *Scanner) scanTokenTransfers(ctx context.Context, from, to uint64, watch map[string]bool) error {
+	if len(s.tokens) == 0 {
+		return nil
+	}
+
+	contracts := make([]common.Address, 0, len(s.tokens))
+	for contract := range s.tokens {
+		contracts = append(contracts, contract)
+	}
+
+	recipients := make([]common.Hash, 0, len(watch))
+	for address := range watch {
+		recipients = append(recipients, common.BytesToHash(common.HexToAddress(address).Bytes()))
+	}
+
+	logs, err := s.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: contracts,
+		Topics:    [][]common.Hash{{erc20TransferTopic}, nil, recipients},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range logs {
+		if entry.Removed || len(entry.Topics) != 3 || len(entry.Data) != 32 {
+			continue
+		}
+
+		token, ok := s.tokens[entry.Address]
+		if !ok {
+			continue
+		}
+
+		value := new(big.Int).SetBytes(entry.Data)
+		s.record(&models.Transaction{
+			Chain:         s.chain,
+			TxHash:        entry.TxHash.Hex(),
+			FromAddress:   common.BytesToAddress(entry.Topics[1].Bytes()).Hex(),
+			ToAddress:     common.BytesToAddress(entry.Topics[2].Bytes()).Hex(),
+			Amount:        decimal.NewFromBigInt(value, -int32(token.Decimals)),
+			Token:         token.Token,
+			TokenContract: entry.Address.Hex(),
+			BlockNumber:   entry.BlockNumber,
+			BlockHash:     entry.BlockHash.Hex(),
+		})
+	}
+
+	return nil
+}
+
+// Confirmations returns how many blocks deep tx currently is. If the
+// transaction was moved to a different block by a reorg, tx is updated to
+// point at its new block.
+func (s *Scanner) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	var receipt *rpcReceipt
+	err := s.client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", common.HexToHash(tx.TxHash))
+	if err != nil {
+		return 0, err
+	}
+	if receipt == nil && tx.BlockHash == "" {
+		// Submitted by the gateway itself, e.g. a relayed authorization; it
+		// is only dropped once the node forgets it
+		var pending map[string]interface{}
+		if err := s.client.Client().CallContext(ctx, &pending, "eth_getTransactionByHash", common.HexToHash(tx.TxHash)); err != nil {
+			return 0, err
+		}
+		if pending != nil {
+			return 0, nil
+		}
+	}
+	if receipt == nil || receipt.Status == 0 {
+		return 0, chains.ErrTransactionDropped
+	}
+
+	if blockHash := receipt.BlockHash.Hex(); blockHash != tx.BlockHash {
+		if tx.BlockHash != "" {
+			log.Printf("Transaction %s moved from block %d to %d", tx.TxHash, tx.BlockNumber, receipt.BlockNumber)
+		}
+		tx.BlockNumber = uint64(receipt.BlockNumber)
+		tx.BlockHash = blockHash
+	}
+
+	head, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if head < tx.BlockNumber {
+		return 0, nil
+	}
+	return int(head-tx.BlockNumber) + 1, nil
+}
+
+func (s *Scanner) record(deposit *models.Transaction) {
+	log.Printf("Detected %s %s on %s to %s in block %d (tx %s)", deposit.Amount, deposit.Token, s.name, deposit.ToAddress, deposit.BlockNumber, deposit.TxHash)
+
+	key := strings.ToLower(deposit.ToAddress)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Blocks rescanned after a reorg report the same transfers again
+	for i, held := range s.deposits[key] {
+		if held.TxHash == deposit.TxHash && held.Token == deposit.Token {
+			s.deposits[key][i] = deposit
+			return
+		}
+	}
+	s.deposits[key] = append(s.deposits[key], deposit)
+}
+
+// TakeDeposit returns and forgets the first recorded transfer of token to
+// address that covers the expected amount.
+func (s *Scanner) TakeDeposit(address string, token models.TokenType, expectedAmount decimal.Decimal) *models.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(address)
+	for i, deposit := range s.deposits[key] {
+		if deposit.Token == token && deposit.Amount.GreaterThanOrEqual(expectedAmount) {
+			s.deposits[key] = append(s.deposits[key][:i], s.deposits[key][i+1:]...)
+			return deposit
+		}
+	}
+	return nil
+}
+
+// pruneDeposits drops recorded transfers to addresses that are no longer
+// watched, e.g. because their payment expired.
+func (s *Scanner) pruneDeposits(watch map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for address := range s.deposits {
+		if !watch[address] {
+			delete(s.deposits, address)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	usdc = chains.Token{
+		Token:    models.TokenUSDC,
+		Symbol:   "USDC",
+		Decimals: 6,
+		Contract: "0x00000000000000000000000000000000000000c0",
+	}
+	testTokens = []chains.Token{
+		{Token: models.TokenNative, Symbol: "ETH", Decimals: 18},
+		usdc,
+	}
+
+	depositAddress = common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	otherAddress   = common.HexToAddress("0x00000000000000000000000000000000000000d2")
+	payer          = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+)
+
+// fakeChain serves blocks, receipts and Transfer logs from memory.
+type fakeChain struct {
+	mu       sync.Mutex
+	head     uint64
+	txs      map[uint64][]map[string]interface{}
+	receipts map[common.Hash]map[string]interface{}
+	logs     []*types.Log
+}
+
+func newFakeChain(t *testing.T, node *fakeNode, head uint64) *fakeChain {
+	c := &fakeChain{
+		head:     head,
+		txs:      make(map[uint64][]map[string]interface{}),
+		receipts: make(map[common.Hash]map[string]interface{}),
+	}
+	node.handle("eth_blockNumber", func([]json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return hexutil.EncodeUint64(c.head), nil
+	})
+	node.handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		number, err := hexutil.DecodeUint64(stringParam(t, params, 0))
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if number > c.head {
+			return nil, nil
+		}
+		txs := c.txs[number]
+		if txs == nil {
+			txs = []map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"number":       hexutil.EncodeUint64(number),
+			"hash":         blockHash(number).Hex(),
+			"transactions": txs,
+		}, nil
+	})
+	node.handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.receipts[common.HexToHash(stringParam(t, params, 0))], nil
+	})
+	node.handle("eth_getLogs", func(params []json.RawMessage) (interface{}, error) {
+		var query struct {
+			FromBlock hexutil.Uint64 `json:"fromBlock"`
+			ToBlock   hexutil.Uint64 `json:"toBlock"`
+		}
+		if err := json.Unmarshal(params[0], &query); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		logs := []*types.Log{}
+		for _, entry := range c.logs {
+			if entry.BlockNumber >= uint64(query.FromBlock) && entry.BlockNumber <= uint64(query.ToBlock) {
+				logs = append(logs, entry)
+			}
+		}
+		return logs, nil
+	})
+	return c
+}
+
+func blockHash(number uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(0x1000 + number))
+}
+
+// transfer adds a native transfer mined in block with the given receipt
+// status.
+func (c *fakeChain) transfer(block uint64, to common.Address, wei int64, status uint64) common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash := common.BigToHash(big.NewInt(int64(block)<<16 | int64(len(c.txs[block])+1)))
+	c.txs[block] = append(c.txs[block], map[string]interface{}{
+		"hash":  hash.Hex(),
+		"from":  payer.Hex(),
+		"to":    to.Hex(),
+		"value": hexutil.EncodeBig(big.NewInt(wei)),
+	})
+	c.receipts[hash] = map[string]interface{}{
+		"blockHash":   blockHash(block).Hex(),
+		"blockNumber": hexutil.EncodeUint64(block),
+		"status":      hexutil.EncodeUint64(status),
+	}
+	return hash
+}
+
+// tokenTransfer adds a Transfer event of token mined in block.
+func (c *fakeChain) tokenTransfer(block uint64, token chains.Token, to common.Address, units int64) common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash := common.BigToHash(big.NewInt(int64(block)<<16 | 0x8000 | int64(len(c.logs))))
+	c.logs = append(c.logs, &types.Log{
+		Address: common.HexToAddress(token.Contract),
+		Topics: []common.Hash{
+			erc20TransferTopic,
+			common.BytesToHash(payer.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:        common.LeftPadBytes(big.NewInt(units).Bytes(), 32),
+		BlockNumber: block,
+		TxHash:      hash,
+		BlockHash:   blockHash(block),
+	})
+	return hash
+}
+
+func (c *fakeChain) setHead(head uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = head
+}
+
+func newTestScanner(t *testing.T, head uint64, store *chaintest.Store) (*Scanner, *fakeChain, *fakeNode) {
+	t.Helper()
+	node, client := newFakeNode(t)
+	chain := newFakeChain(t, node, head)
+	return NewScanner(client, store, models.ChainEthereum, "Ethereum", testTokens, 3), chain, node
+}
+
+func ether(amount string) decimal.Decimal {
+	return decimal.RequireFromString(amount)
+}
+
+func TestScanDetectsTransfers(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(c *fakeChain) common.Hash
+		token   models.TokenType
+		amount  decimal.Decimal
+		want    bool
+		receipt bool // whether the scanner has to look at the receipt
+	}{
+		{
+			name:    "native transfer",
+			setup:   func(c *fakeChain) common.Hash { return c.transfer(11, depositAddress, 1e18, 1) },
+			token:   models.TokenNative,
+			amount:  ether("1"),
+			want:    true,
+			receipt: true,
+		},
+		{
+			name:    "native overpayment",
+			setup:   func(c *fakeChain) common.Hash { return c.transfer(12, depositAddress, 2e18, 1) },
+			token:   models.TokenNative,
+			amount:  ether("1"),
+			want:    true,
+			receipt: true,
+		},
+		{
+			name:    "native underpayment",
+			setup:   func(c *fakeChain) common.Hash { return c.transfer(11, depositAddress, 5e17, 1) },
+			token:   models.TokenNative,
+			amount:  ether("1"),
+			receipt: true,
+		},
+		{
+			name:    "reverted native transfer",
+			setup:   func(c *fakeChain) common.Hash { return c.transfer(11, depositAddress, 1e18, 0) },
+			token:   models.TokenNative,
+			amount:  ether("1"),
+			receipt: true,
+		},
+		{
+			name:   "native transfer to another address",
+			setup:  func(c *fakeChain) common.Hash { return c.transfer(11, otherAddress, 1e18, 1) },
+			token:  models.TokenNative,
+			amount: ether("1"),
+		},
+		{
+			name:   "token transfer",
+			setup:  func(c *fakeChain) common.Hash { return c.tokenTransfer(13, usdc, depositAddress, 25_000_000) },
+			token:  models.TokenUSDC,
+			amount: ether("25"),
+			want:   true,
+		},
+		{
+			name:   "token transfer paying another token",
+			setup:  func(c *fakeChain) common.Hash { return c.tokenTransfer(13, usdc, depositAddress, 25_000_000) },
+			token:  models.TokenNative,
+			amount: ether("25"),
+		},
+		{
+			name:   "token underpayment",
+			setup:  func(c *fakeChain) common.Hash { return c.tokenTransfer(13, usdc, depositAddress, 24_999_999) },
+			token:  models.TokenUSDC,
+			amount: ether("25"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := chaintest.NewStore()
+			store.SaveCursor(models.ChainEthereum, chains.Cursor{Height: 10})
+			scanner, chain, node := newTestScanner(t, 15, store)
+			hash := tt.setup(chain)
+
+			if err := scanner.Scan(context.Background(), []string{depositAddress.Hex()}); err != nil {
+				t.Fatal(err)
+			}
+			if got := node.callCount("eth_getTransactionReceipt") > 0; got != tt.receipt {
+				t.Errorf("receipt fetched = %v, want %v", got, tt.receipt)
+			}
+
+			deposit := scanner.TakeDeposit(depositAddress.Hex(), tt.token, tt.amount)
+			if (deposit != nil) != tt.want {
+				t.Fatalf("TakeDeposit() = %+v, want a deposit: %v", deposit, tt.want)
+			}
+			if deposit == nil {
+				return
+			}
+			if deposit.TxHash != hash.Hex() || deposit.FromAddress != payer.Hex() {
+				t.Errorf("deposit = %+v, want tx %s from %s", deposit, hash.Hex(), payer.Hex())
+			}
+			if scanner.TakeDeposit(depositAddress.Hex(), tt.token, tt.amount) != nil {
+				t.Error("deposit handed out twice")
+			}
+		})
+	}
+}
+
+func TestScanHoldsCursorUntilDepositsAreTaken(t *testing.T) {
+	store := chaintest.NewStore()
+	store.SaveCursor(models.ChainEthereum, chains.Cursor{Height: 10})
+	scanner, chain, _ := newTestScanner(t, 20, store)
+	chain.transfer(13, depositAddress, 1e18, 1)
+	chain.tokenTransfer(15, usdc, otherAddress, 1_000_000)
+	watched := []string{depositAddress.Hex(), otherAddress.Hex()}
+
+	cursor := func() uint64 {
+		t.Helper()
+		saved, _ := store.LoadCursor(models.ChainEthereum)
+		return saved.Height
+	}
+
+	if err := scanner.Scan(context.Background(), watched); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursor(); got != 12 {
+		t.Fatalf("cursor = %d, want 12 before the block of the first untaken transfer", got)
+	}
+
+	// A restart forgets the detections, but scans their blocks again
+	restarted := NewScanner(scanner.client, store, models.ChainEthereum, "Ethereum", testTokens, 3)
+	if err := restarted.Scan(context.Background(), watched); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.TakeDeposit(depositAddress.Hex(), models.TokenNative, ether("1")) == nil {
+		t.Fatal("native transfer not detected again after a restart")
+	}
+	if err := restarted.Scan(context.Background(), watched); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursor(); got != 14 {
+		t.Fatalf("cursor = %d, want 14 once only the token transfer is held", got)
+	}
+
+	// Transfers to addresses that are no longer watched are dropped
+	chain.setHead(22)
+	if err := restarted.Scan(context.Background(), []string{depositAddress.Hex()}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursor(); got != 22 {
+		t.Fatalf("cursor = %d, want the head 22", got)
+	}
+}
+
+func TestScanRecordsRescannedTransfersOnce(t *testing.T) {
+	store := chaintest.NewStore()
+	store.SaveCursor(models.ChainEthereum, chains.Cursor{Height: 10})
+	scanner, chain, _ := newTestScanner(t, 12, store)
+	chain.transfer(12, depositAddress, 1e18, 1)
+	watched := []string{depositAddress.Hex()}
+
+	if err := scanner.Scan(context.Background(), watched); err != nil {
+		t.Fatal(err)
+	}
+	// The last scanned block was replaced, so the scanner rewinds
+	scanner.position.Hash = common.Hash{}.Hex()
+	if err := scanner.Scan(context.Background(), watched); err != nil {
+		t.Fatal(err)
+	}
+
+	if scanner.TakeDeposit(depositAddress.Hex(), models.TokenNative, ether("1")) == nil {
+		t.Fatal("transfer not detected")
+	}
+	if scanner.TakeDeposit(depositAddress.Hex(), models.TokenNative, ether("1")) != nil {
+		t.Error("rescanned transfer recorded twice")
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	mined := common.HexToHash("0x0a")
+	tests := []struct {
+		name      string
+		receipt   interface{}
+		pending   interface{}
+		blockHash string
+		want      int
+		wantBlock uint64
+		wantErr   error
+	}{
+		{
+			name:      "mined",
+			receipt:   map[string]interface{}{"blockHash": blockHash(18).Hex(), "blockNumber": "0x12", "status": "0x1"},
+			blockHash: blockHash(18).Hex(),
+			want:      3,
+			wantBlock: 18,
+		},
+		{
+			name:      "moved by a reorg",
+			receipt:   map[string]interface{}{"blockHash": blockHash(19).Hex(), "blockNumber": "0x13", "status": "0x1"},
+			blockHash: blockHash(18).Hex(),
+			want:      2,
+			wantBlock: 19,
+		},
+		{
+			name:      "reverted",
+			receipt:   map[string]interface{}{"blockHash": blockHash(18).Hex(), "blockNumber": "0x12", "status": "0x0"},
+			blockHash: blockHash(18).Hex(),
+			wantErr:   chains.ErrTransactionDropped,
+		},
+		{
+			name:      "dropped",
+			blockHash: blockHash(18).Hex(),
+			wantErr:   chains.ErrTransactionDropped,
+		},
+		{
+			name:    "relayed and pending",
+			pending: map[string]interface{}{"hash": mined.Hex()},
+		},
+		{
+			name:    "relayed and forgotten",
+			wantErr: chains.ErrTransactionDropped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner, _, node := newTestScanner(t, 20, chaintest.NewStore())
+			node.result("eth_getTransactionReceipt", tt.receipt)
+			node.result("eth_getTransactionByHash", tt.pending)
+
+			tx := &models.Transaction{TxHash: mined.Hex(), BlockHash: tt.blockHash, BlockNumber: 18}
+			if tt.blockHash == "" {
+				tx.BlockNumber = 0
+			}
+			confirmations, err := scanner.Confirmations(context.Background(), tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if confirmations != tt.want {
+				t.Errorf("confirmations = %d, want %d", confirmations, tt.want)
+			}
+			if tt.wantBlock != 0 && tx.BlockNumber != tt.wantBlock {
+				t.Errorf("block = %d, want %d", tx.BlockNumber, tt.wantBlock)
+			}
+		})
+	}
+}
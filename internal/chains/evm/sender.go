@@ -0,0 +1,146 @@
+package evm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Gas limits of outgoing transfers. ERC-20 transfers vary by contract;
+// the limit covers the common stablecoins with headroom.
+const (
+	nativeTransferGas = 21000
+	tokenTransferGas  = 100000
+)
+
+// ERC-20 function selectors
+var (
+	erc20BalanceOf = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+	erc20Transfer  = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+)
+
+func parsePrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	return crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+}
+
+func (a *Adapter) AddressOf(privateKey string) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey).Hex(), nil
+}
+
+func (a *Adapter) Balance(ctx context.Context, address string, token chains.Token) (*big.Int, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+
+	account := common.HexToAddress(address)
+	if token.Token == models.TokenNative {
+		return a.client.BalanceAt(ctx, account, nil)
+	}
+
+	contract := common.HexToAddress(token.Contract)
+	result, err := a.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: append(append([]byte{}, erc20BalanceOf...), common.LeftPadBytes(account.Bytes(), 32)...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(result), nil
+}
+
+// TransferFee is the most a transfer of token can cost at the current fee
+// cap.
+func (a *Adapter) TransferFee(ctx context.Context, token chains.Token) (*big.Int, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+
+	_, feeCap, err := a.txs.Fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mul(feeCap, big.NewInt(int64(transferGas(token)))), nil
+}
+
+func transferGas(token chains.Token) uint64 {
+	if token.Token == models.TokenNative {
+		return nativeTransferGas
+	}
+	return tokenTransferGas
+}
+
+func (a *Adapter) Transfer(ctx context.Context, req chains.TransferRequest) (string, error) {
+	if a.client == nil {
+		return "", fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+	if err := a.checkChainID(ctx); err != nil {
+		return "", err
+	}
+
+	key, err := parsePrivateKey(req.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	if !common.IsHexAddress(req.To) {
+		return "", fmt.Errorf("invalid %s recipient %q", a.config.Name, req.To)
+	}
+
+	to := common.HexToAddress(req.To)
+	value := req.Amount
+	var data []byte
+	if req.Token.Token != models.TokenNative {
+		// Call transfer(to, amount) on the token contract instead
+		data = append(append([]byte{}, erc20Transfer...), common.LeftPadBytes(to.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(req.Amount.Bytes(), 32)...)
+		to = common.HexToAddress(req.Token.Contract)
+		value = new(big.Int)
+	}
+
+	return a.txs.Send(ctx, key, to, value, data, transferGas(req.Token), req.Record)
+}
+
+// TrackTransfer follows a transfer up through the transaction manager,
+// replacing it with higher fees if it is stuck.
+func (a *Adapter) TrackTransfer(ctx context.Context, record *models.OutgoingTransaction, privateKey string) (chains.TransferStatus, error) {
+	if a.client == nil {
+		return chains.TransferPending, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return chains.TransferPending, err
+	}
+	return a.txs.Track(ctx, record, key)
+}
+
+func (a *Adapter) TransferStatus(ctx context.Context, txHash string) (chains.TransferStatus, error) {
+	if a.client == nil {
+		return chains.TransferPending, fmt.Errorf("no %s RPC configured", a.config.Name)
+	}
+
+	var receipt *rpcReceipt
+	err := a.client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) || (err == nil && receipt == nil) {
+		return chains.TransferPending, nil
+	}
+	if err != nil {
+		return chains.TransferPending, err
+	}
+	if receipt.Status == 0 {
+		return chains.TransferFailed, nil
+	}
+	return chains.TransferSucceeded, nil
+}
@@ -0,0 +1,344 @@
+package evm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/shopspring/decimal"
+)
+
+// nativeDecimals is the precision of every EVM network's native asset.
+const nativeDecimals = 18
+
+// stuckAfter is how long a transaction may stay unmined before it is
+// replaced with higher fees.
+const stuckAfter = 3 * time.Minute
+
+// A replacement must raise both fees by at least 10% for nodes to accept
+// it; bumps add 12.5%.
+var (
+	bumpNumerator   = big.NewInt(9)
+	bumpDenominator = big.NewInt(8)
+)
+
+// TxManager signs and broadcasts EIP-1559 transactions for one network. It
+// allocates nonces per sending address, so concurrent sends never collide,
+// and replaces transactions that get stuck with higher fees. Nonces are
+// persisted through the chain store; the state of each transaction lives
+// in its OutgoingTransaction record.
+type TxManager struct {
+	client  *ethclient.Client
+	store   chains.Store
+	chain   models.Chain
+	name    string
+	chainID *big.Int
+
+	mu    sync.Mutex
+	locks map[common.Address]*sync.Mutex
+}
+
+func NewTxManager(client *ethclient.Client, store chains.Store, chain models.Chain, name string, chainID int64) *TxManager {
+	return &TxManager{
+		client:  client,
+		store:   store,
+		chain:   chain,
+		name:    name,
+		chainID: big.NewInt(chainID),
+		locks:   make(map[common.Address]*sync.Mutex),
+	}
+}
+
+// lock serializes sends from address.
+func (m *TxManager) lock(address common.Address) func() {
+	m.mu.Lock()
+	lock, ok := m.locks[address]
+	if !ok {
+		lock = new(sync.Mutex)
+		m.locks[address] = lock
+	}
+	m.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// Fees returns a priority fee and a fee cap that stays valid through a few
+// blocks of rising base fees.
+func (m *TxManager) Fees(ctx context.Context) (tip, feeCap *big.Int, err error) {
+	tip, err = m.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := m.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("%s does not support EIP-1559 transactions", m.name)
+	}
+
+	feeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+	return tip, feeCap.Add(feeCap, tip), nil
+}
+
+// Send signs a transaction from key with the next free nonce and current
+// fees, broadcasts it and returns its hash. If record is not nil, the
+// transaction's state is stored in it for Track.
+func (m *TxManager) Send(ctx context.Context, key *ecdsa.PrivateKey, to common.Address, value *big.Int, data []byte, gas uint64, record *models.OutgoingTransaction) (string, error) {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	defer m.lock(from)()
+
+	tip, feeCap, err := m.Fees(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The node's pending nonce covers transactions sent by others or before
+	// the counter existed; the counter covers ones the node has forgotten.
+	pending, err := m.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := m.store.ReserveNonce(m.chain, from.Hex(), pending)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := m.sign(key, &types.DynamicFeeTx{
+		ChainID:   m.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+	if err == nil {
+		err = m.client.SendTransaction(ctx, signed)
+	}
+	if err != nil {
+		if releaseErr := m.store.ReleaseNonce(m.chain, from.Hex(), nonce); releaseErr != nil {
+			return "", fmt.Errorf("%w (releasing nonce %d: %v)", err, nonce, releaseErr)
+		}
+		return "", err
+	}
+
+	if record != nil {
+		if err := storeTransaction(record, signed); err != nil {
+			return "", err
+		}
+	}
+	return signed.Hash().Hex(), nil
+}
+
+func (m *TxManager) sign(key *ecdsa.PrivateKey, tx *types.DynamicFeeTx) (*types.Transaction, error) {
+	return types.SignTx(types.NewTx(tx), types.LatestSignerForChainID(m.chainID), key)
+}
+
+func storeTransaction(record *models.OutgoingTransaction, signed *types.Transaction) error {
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	nonce := signed.Nonce()
+	now := time.Now()
+	record.TxHash = signed.Hash().Hex()
+	record.Nonce = &nonce
+	record.GasTipCap = signed.GasTipCap().String()
+	record.GasFeeCap = signed.GasFeeCap().String()
+	record.RawTx = hexutil.Encode(raw)
+	record.SubmittedAt = &now
+	return nil
+}
+
+// Track reports whether the transaction of record, or any transaction it
+// replaced, was mined. A transaction unmined for longer than stuckAfter is
+// replaced by one with the same nonce and bumped fees, signed with key.
+func (m *TxManager) Track(ctx context.Context, record *models.OutgoingTransaction, key *ecdsa.PrivateKey) (chains.TransferStatus, error) {
+	status, err := m.receiptStatus(ctx, record)
+	if err != nil || status != chains.TransferPending {
+		return status, err
+	}
+
+	if record.Nonce == nil || record.RawTx == "" {
+		return chains.TransferPending, nil
+	}
+
+	from := common.HexToAddress(record.FromAddress)
+	mined, err := m.client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return chains.TransferPending, err
+	}
+	if mined > *record.Nonce {
+		// One of our transactions may have been mined since the receipts
+		// were checked; only without a receipt was the nonce taken by a
+		// transaction sent from elsewhere
+		status, err := m.receiptStatus(ctx, record)
+		if err != nil || status != chains.TransferPending {
+			return status, err
+		}
+		record.Error = fmt.Sprintf("nonce %d was used by another transaction", *record.Nonce)
+		return chains.TransferFailed, nil
+	}
+
+	if record.SubmittedAt != nil && time.Since(*record.SubmittedAt) < stuckAfter {
+		return chains.TransferPending, nil
+	}
+	if err := m.replace(ctx, record, key); err != nil {
+		return chains.TransferPending, fmt.Errorf("replacing stuck transaction %s: %w", record.TxHash, err)
+	}
+	return chains.TransferPending, nil
+}
+
+// receiptStatus looks for a receipt of the transaction of record or of any
+// transaction it replaced, and reports pending if there is none. The hash
+// that was mined becomes the record's.
+func (m *TxManager) receiptStatus(ctx context.Context, record *models.OutgoingTransaction) (chains.TransferStatus, error) {
+	hashes := []string{record.TxHash}
+	if record.ReplacedHashes != "" {
+		hashes = append(hashes, strings.Split(record.ReplacedHashes, ",")...)
+	}
+
+	// Any of the replacements may be the one that made it
+	for _, hash := range hashes {
+		var receipt *rpcReceipt
+		err := m.client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", common.HexToHash(hash))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return chains.TransferPending, err
+		}
+		if receipt == nil {
+			continue
+		}
+
+		record.TxHash = hash
+		if receipt.Status == 0 {
+			return chains.TransferFailed, nil
+		}
+		return chains.TransferSucceeded, nil
+	}
+	return chains.TransferPending, nil
+}
+
+// replace rebroadcasts the transaction of record with bumped fees, or the
+// current ones if they are higher still. A plain transfer of the sender's
+// whole balance, such as a native sweep, leaves no room for higher fees,
+// so its value is lowered by what they add.
+func (m *TxManager) replace(ctx context.Context, record *models.OutgoingTransaction, key *ecdsa.PrivateKey) error {
+	raw, err := hexutil.Decode(record.RawTx)
+	if err != nil {
+		return err
+	}
+	var previous types.Transaction
+	if err := previous.UnmarshalBinary(raw); err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != common.HexToAddress(record.FromAddress) {
+		return errors.New("key does not match the sending address")
+	}
+
+	tip, feeCap, err := m.Fees(ctx)
+	if err != nil {
+		return err
+	}
+	tip = maxBig(tip, bump(previous.GasTipCap()))
+	feeCap = maxBig(feeCap, bump(previous.GasFeeCap()))
+	if feeCap.Cmp(tip) < 0 {
+		feeCap = new(big.Int).Set(tip)
+	}
+
+	value, err := m.replacementValue(ctx, &previous, feeCap)
+	if err != nil {
+		return err
+	}
+
+	signed, err := m.sign(key, &types.DynamicFeeTx{
+		ChainID:   m.chainID,
+		Nonce:     previous.Nonce(),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       previous.Gas(),
+		To:        previous.To(),
+		Value:     value,
+		Data:      previous.Data(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.client.SendTransaction(ctx, signed); err != nil {
+		return err
+	}
+	if value.Cmp(previous.Value()) != 0 {
+		record.Amount = decimal.NewFromBigInt(value, -nativeDecimals)
+	}
+
+	replaced := record.TxHash
+	if err := storeTransaction(record, signed); err != nil {
+		return err
+	}
+	if record.ReplacedHashes != "" {
+		replaced += "," + record.ReplacedHashes
+	}
+	record.ReplacedHashes = replaced
+	return nil
+}
+
+// replacementValue returns the value of previous's replacement with
+// feeCap. A plain transfer that cannot afford the higher worst-case fee
+// on top of its value, as happens to sweeps of a whole balance, gives up
+// the difference; others keep their value.
+func (m *TxManager) replacementValue(ctx context.Context, previous *types.Transaction, feeCap *big.Int) (*big.Int, error) {
+	value := previous.Value()
+	if len(previous.Data()) > 0 || value.Sign() == 0 {
+		return value, nil
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(m.chainID), previous)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := m.client.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(previous.Gas()), feeCap)
+	if new(big.Int).Add(value, fee).Cmp(balance) <= 0 {
+		return value, nil
+	}
+
+	value = new(big.Int).Sub(balance, fee)
+	if value.Sign() <= 0 {
+		return nil, fmt.Errorf("balance of %s cannot cover the bumped fees", from.Hex())
+	}
+	return value, nil
+}
+
+func bump(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, bumpNumerator)
+	bumped.Div(bumped, bumpDenominator)
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
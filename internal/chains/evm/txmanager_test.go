@@ -0,0 +1,300 @@
+package evm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	testTip     = 2_000_000_000
+	testBaseFee = 10_000_000_000
+	testFeeCap  = 2*testBaseFee + testTip
+)
+
+var treasury = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+func newTestTxManager(t *testing.T) (*TxManager, *fakeNode, *ecdsa.PrivateKey) {
+	t.Helper()
+	node, client := newFakeNode(t)
+	node.result("eth_maxPriorityFeePerGas", hexutil.EncodeBig(big.NewInt(testTip)))
+	node.result("eth_getBlockByNumber", header(100, testBaseFee))
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTxManager(client, chaintest.NewStore(), models.ChainEthereum, "Ethereum", 1), node, key
+}
+
+func receipt(status uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"blockHash":   common.HexToHash("0x01").Hex(),
+		"blockNumber": "0x64",
+		"status":      hexutil.EncodeUint64(status),
+	}
+}
+
+func TestFees(t *testing.T) {
+	m, _, _ := newTestTxManager(t)
+
+	tip, feeCap, err := m.Fees(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Int64() != testTip || feeCap.Int64() != testFeeCap {
+		t.Errorf("Fees() = %s, %s, want %d, %d", tip, feeCap, testTip, testFeeCap)
+	}
+}
+
+func TestSendAllocatesDistinctNonces(t *testing.T) {
+	m, node, key := newTestTxManager(t)
+	node.result("eth_getTransactionCount", "0x3")
+	node.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		return rawTransaction(t, params).Hash().Hex(), nil
+	})
+
+	const sends = 8
+	records := make([]models.OutgoingTransaction, sends)
+	var wg sync.WaitGroup
+	for i := range records {
+		wg.Add(1)
+		go func(record *models.OutgoingTransaction) {
+			defer wg.Done()
+			if _, err := m.Send(context.Background(), key, treasury, big.NewInt(1), nil, nativeTransferGas, record); err != nil {
+				t.Error(err)
+			}
+		}(&records[i])
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		if record.Nonce == nil {
+			t.Fatal("nonce not recorded")
+		}
+		if seen[*record.Nonce] {
+			t.Errorf("nonce %d handed out twice", *record.Nonce)
+		}
+		seen[*record.Nonce] = true
+		if *record.Nonce < 3 || *record.Nonce >= 3+sends {
+			t.Errorf("nonce %d outside [3, %d)", *record.Nonce, 3+sends)
+		}
+		if record.RawTx == "" || record.TxHash == "" || record.SubmittedAt == nil {
+			t.Errorf("transaction state not recorded: %+v", record)
+		}
+	}
+}
+
+func TestSendReleasesNonceOfFailedBroadcast(t *testing.T) {
+	m, node, key := newTestTxManager(t)
+	node.result("eth_getTransactionCount", "0x0")
+	fail := true
+	node.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		if fail {
+			return nil, errors.New("insufficient funds")
+		}
+		return rawTransaction(t, params).Hash().Hex(), nil
+	})
+
+	if _, err := m.Send(context.Background(), key, treasury, big.NewInt(1), nil, nativeTransferGas, nil); err == nil {
+		t.Fatal("expected the broadcast error")
+	}
+
+	fail = false
+	var record models.OutgoingTransaction
+	if _, err := m.Send(context.Background(), key, treasury, big.NewInt(1), nil, nativeTransferGas, &record); err != nil {
+		t.Fatal(err)
+	}
+	if *record.Nonce != 0 {
+		t.Errorf("nonce = %d, want the released nonce 0", *record.Nonce)
+	}
+}
+
+// sentRecord sends a transfer through m and returns its record, as
+// submitted submittedAgo.
+func sentRecord(t *testing.T, m *TxManager, node *fakeNode, key *ecdsa.PrivateKey, value *big.Int, submittedAgo time.Duration) *models.OutgoingTransaction {
+	t.Helper()
+	node.result("eth_getTransactionCount", "0x5")
+	node.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		return rawTransaction(t, params).Hash().Hex(), nil
+	})
+
+	record := &models.OutgoingTransaction{
+		FromAddress: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Token:       models.TokenNative,
+	}
+	if _, err := m.Send(context.Background(), key, treasury, value, nil, nativeTransferGas, record); err != nil {
+		t.Fatal(err)
+	}
+	submitted := time.Now().Add(-submittedAgo)
+	record.SubmittedAt = &submitted
+	return record
+}
+
+func TestTrack(t *testing.T) {
+	tests := []struct {
+		name string
+		// receipts answers eth_getTransactionReceipt for the current and
+		// the replaced hash, per call
+		receipts    func(call int, current bool) interface{}
+		minedNonce  string
+		age         time.Duration
+		want        chains.TransferStatus
+		wantError   bool
+		wantReplace bool
+	}{
+		{
+			name:       "mined",
+			receipts:   func(int, bool) interface{} { return receipt(1) },
+			minedNonce: "0x6",
+			want:       chains.TransferSucceeded,
+		},
+		{
+			name:       "reverted",
+			receipts:   func(int, bool) interface{} { return receipt(0) },
+			minedNonce: "0x6",
+			want:       chains.TransferFailed,
+		},
+		{
+			name:       "waiting",
+			receipts:   func(int, bool) interface{} { return nil },
+			minedNonce: "0x5",
+			want:       chains.TransferPending,
+		},
+		{
+			name:       "nonce taken elsewhere",
+			receipts:   func(int, bool) interface{} { return nil },
+			minedNonce: "0x6",
+			want:       chains.TransferFailed,
+			wantError:  true,
+		},
+		{
+			name: "mined between receipt and nonce checks",
+			receipts: func(call int, _ bool) interface{} {
+				if call == 1 {
+					return nil
+				}
+				return receipt(1)
+			},
+			minedNonce: "0x6",
+			want:       chains.TransferSucceeded,
+		},
+		{
+			name:        "stuck",
+			receipts:    func(int, bool) interface{} { return nil },
+			minedNonce:  "0x5",
+			age:         stuckAfter + time.Minute,
+			want:        chains.TransferPending,
+			wantReplace: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, node, key := newTestTxManager(t)
+			record := sentRecord(t, m, node, key, big.NewInt(1), tt.age)
+			sent := record.TxHash
+
+			calls := 0
+			node.handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+				calls++
+				return tt.receipts(calls, stringParam(t, params, 0) == sent), nil
+			})
+			node.handle("eth_getTransactionCount", func(params []json.RawMessage) (interface{}, error) {
+				if stringParam(t, params, 1) == "pending" {
+					return "0x6", nil
+				}
+				return tt.minedNonce, nil
+			})
+			node.result("eth_getBalance", hexutil.EncodeBig(big.NewInt(1e18)))
+
+			status, err := m.Track(context.Background(), record, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.want {
+				t.Errorf("status = %v, want %v", status, tt.want)
+			}
+			if (record.Error != "") != tt.wantError {
+				t.Errorf("error = %q", record.Error)
+			}
+			if replaced := record.ReplacedHashes == sent; replaced != tt.wantReplace {
+				t.Errorf("replaced hashes = %q, want replacement %v", record.ReplacedHashes, tt.wantReplace)
+			}
+		})
+	}
+}
+
+func TestReplaceBumpsFees(t *testing.T) {
+	m, node, key := newTestTxManager(t)
+	record := sentRecord(t, m, node, key, big.NewInt(1000), stuckAfter)
+	node.result("eth_getBalance", hexutil.EncodeBig(big.NewInt(1e18)))
+
+	var replacement *types.Transaction
+	node.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		replacement = rawTransaction(t, params)
+		return replacement.Hash().Hex(), nil
+	})
+	if err := m.replace(context.Background(), record, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if replacement.Nonce() != 5 {
+		t.Errorf("nonce = %d, want 5", replacement.Nonce())
+	}
+	if want := bump(big.NewInt(testFeeCap)); replacement.GasFeeCap().Cmp(want) != 0 {
+		t.Errorf("fee cap = %s, want %s", replacement.GasFeeCap(), want)
+	}
+	if want := bump(big.NewInt(testTip)); replacement.GasTipCap().Cmp(want) != 0 {
+		t.Errorf("tip = %s, want %s", replacement.GasTipCap(), want)
+	}
+	if replacement.Value().Int64() != 1000 {
+		t.Errorf("value = %s, want it unchanged", replacement.Value())
+	}
+}
+
+func TestReplaceLowersWholeBalanceTransfer(t *testing.T) {
+	m, node, key := newTestTxManager(t)
+
+	// A native sweep leaves exactly its worst-case fee behind
+	balance := big.NewInt(1e18)
+	fee := big.NewInt(testFeeCap * nativeTransferGas)
+	record := sentRecord(t, m, node, key, new(big.Int).Sub(balance, fee), stuckAfter)
+	node.result("eth_getBalance", hexutil.EncodeBig(balance))
+
+	var replacement *types.Transaction
+	node.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		replacement = rawTransaction(t, params)
+		return replacement.Hash().Hex(), nil
+	})
+	if err := m.replace(context.Background(), record, key); err != nil {
+		t.Fatal(err)
+	}
+
+	cost := new(big.Int).Mul(replacement.GasFeeCap(), new(big.Int).SetUint64(replacement.Gas()))
+	if total := cost.Add(cost, replacement.Value()); total.Cmp(balance) != 0 {
+		t.Errorf("value plus worst-case fee = %s, want the balance %s", total, balance)
+	}
+	if !record.Amount.Equal(decimalWei(replacement.Value())) {
+		t.Errorf("recorded amount = %s, want %s", record.Amount, decimalWei(replacement.Value()))
+	}
+}
+
+func decimalWei(wei *big.Int) decimal.Decimal {
+	return decimal.NewFromBigInt(wei, -nativeDecimals)
+}
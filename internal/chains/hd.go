@@ -0,0 +1,29 @@
+package chains
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// ParseAccountXPub parses a BIP32 account-level extended public key and
+// returns the key for its external (receive) chain.
+func ParseAccountXPub(encoded string) (*hdkeychain.ExtendedKey, error) {
+	key, err := hdkeychain.NewKeyFromString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("expected an extended public key, got a private one")
+	}
+	return key.Derive(0)
+}
+
+// DeriveChild derives the non-hardened child at index, rejecting indexes in
+// the hardened range which cannot be derived from public material.
+func DeriveChild(key *hdkeychain.ExtendedKey, index uint32) (*hdkeychain.ExtendedKey, error) {
+	if index >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("derivation index %d out of range", index)
+	}
+	return key.Derive(index)
+}
@@ -0,0 +1,67 @@
+package chains
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Account xpub at m/44'/60'/0' of the BIP39 test mnemonic "abandon
+// abandon ... about".
+const testAccountXPub = "xpub6DCoCpSuQZB2jawqnGMEPS63ePKWkwWPH4TU45Q7LPXWuNd8TMtVxRrgjtEshuqpK3mdhaWHPFsBngh5GFZaM6si3yZdUsT8ddYM3PwnATt"
+
+// BIP32 test vector 1 master key.
+const testMasterXPrv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+
+func TestDeriveChild(t *testing.T) {
+	account, err := ParseAccountXPub(testAccountXPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		index   uint32
+		address string
+	}{
+		{0, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{1, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"},
+	}
+	for _, tt := range tests {
+		child, err := DeriveChild(account, tt.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := child.ECPubKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := crypto.PubkeyToAddress(*publicKey.ToECDSA()).Hex(); got != tt.address {
+			t.Errorf("m/44'/60'/0'/0/%d = %s, want %s", tt.index, got, tt.address)
+		}
+	}
+
+	if _, err := DeriveChild(account, hdkeychain.HardenedKeyStart); err == nil {
+		t.Error("derived a hardened child from public material")
+	}
+}
+
+func TestParseAccountXPub(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		valid   bool
+	}{
+		{"account xpub", testAccountXPub, true},
+		{"private key", testMasterXPrv, false},
+		{"bad checksum", testAccountXPub[:len(testAccountXPub)-1] + "u", false},
+		{"garbage", "not-an-xpub", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAccountXPub(tt.encoded); (err == nil) != tt.valid {
+				t.Errorf("ParseAccountXPub() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
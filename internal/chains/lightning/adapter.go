@@ -0,0 +1,241 @@
+package lightning
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// satoshiDecimals is the number of decimals of a bitcoin.
+const satoshiDecimals = 8
+
+// pollInterval is how often settled invoices are matched to payments. The
+// check is an in-memory lookup, so it can run often.
+const pollInterval = 5 * time.Second
+
+// reconnectDelay is how long to wait before resubscribing after the
+// invoice stream breaks.
+const reconnectDelay = 5 * time.Second
+
+// Config configures the Lightning adapter.
+type Config struct {
+	RESTURL     string
+	Macaroon    string // hex-encoded invoice macaroon
+	TLSCertPath string // LND's tls.cert; system roots if empty
+	Tokens      []chains.Token
+}
+
+// Adapter accepts Lightning payments to invoices issued by an LND node.
+// Settlements arrive through LND's invoice subscription stream.
+type Adapter struct {
+	config Config
+	store  chains.Store
+	lnd    *lndClient
+
+	subscribed bool // only touched by Sync, which runs on one goroutine
+
+	mu          sync.Mutex
+	settled     map[string]*invoice // keyed by hex payment hash
+	settleIndex uint64
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	lnd, err := newLNDClient(cfg.RESTURL, cfg.Macaroon, cfg.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LND configuration: %w", err)
+	}
+
+	return &Adapter{
+		config:  cfg,
+		store:   store,
+		lnd:     lnd,
+		settled: make(map[string]*invoice),
+	}, nil
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return models.ChainLightning
+}
+
+// NewAddress is not supported: Lightning payments need an invoice for the
+// exact amount, see NewInvoice.
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	return nil, errors.New("lightning payments are made to invoices, not addresses")
+}
+
+// NewInvoice issues a BOLT11 invoice for the amount, rounded up to whole
+// satoshis, that expires with the payment.
+func (a *Adapter) NewInvoice(ctx context.Context, req chains.InvoiceRequest) (*chains.Invoice, error) {
+	sats := req.Amount.Shift(satoshiDecimals).Ceil().IntPart()
+	if sats <= 0 {
+		return nil, fmt.Errorf("invoice amount %s BTC is too small", req.Amount)
+	}
+
+	expiry := int64(time.Until(req.ExpiresAt).Seconds())
+	if expiry <= 0 {
+		return nil, fmt.Errorf("payment %s has already expired", req.PaymentID)
+	}
+
+	resp, err := a.lnd.addInvoice(ctx, addInvoiceRequest{
+		Memo:   "Payment " + req.PaymentID,
+		Value:  sats,
+		Expiry: expiry,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Invoice{
+		Request:   resp.PaymentRequest,
+		Reference: hex.EncodeToString(resp.RHash),
+	}, nil
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	if !strings.HasPrefix(strings.ToLower(address), "ln") {
+		return fmt.Errorf("invalid BOLT11 payment request %q", address)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+// Sync starts the invoice subscription on first use, after looking up the
+// awaited invoices once in case they were settled while the gateway was
+// down. It also forgets settlements no payment is waiting for anymore.
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	if !a.subscribed {
+		cursor, err := a.store.LoadCursor(models.ChainLightning)
+		if err != nil {
+			return err
+		}
+		if cursor != nil {
+			a.settleIndex = cursor.Height
+		}
+		if err := a.lookupDeposits(ctx, deposits); err != nil {
+			return err
+		}
+		a.subscribed = true
+		go a.subscribeInvoices()
+	}
+
+	awaited := make(map[string]bool, len(deposits))
+	for _, deposit := range deposits {
+		awaited[deposit.Reference] = true
+	}
+
+	a.mu.Lock()
+	for hash := range a.settled {
+		if !awaited[hash] {
+			delete(a.settled, hash)
+		}
+	}
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Adapter) lookupDeposits(ctx context.Context, deposits []chains.Deposit) error {
+	for _, deposit := range deposits {
+		if deposit.Reference == "" {
+			continue
+		}
+		inv, err := a.lnd.lookupInvoice(ctx, deposit.Reference)
+		if err != nil {
+			return err
+		}
+		a.handleInvoice(inv)
+	}
+	return nil
+}
+
+// subscribeInvoices follows LND's invoice stream for the lifetime of the
+// process. The last settle index is persisted as the chain cursor, so
+// settlements that happen while the gateway is down are replayed.
+func (a *Adapter) subscribeInvoices() {
+	for {
+		a.mu.Lock()
+		settleIndex := a.settleIndex
+		a.mu.Unlock()
+
+		err := a.lnd.subscribeInvoices(context.Background(), settleIndex, a.handleInvoice)
+		log.Printf("Lightning invoice subscription ended: %v", err)
+		time.Sleep(reconnectDelay)
+	}
+}
+
+func (a *Adapter) handleInvoice(inv *invoice) {
+	if inv.State != "SETTLED" {
+		return
+	}
+
+	a.mu.Lock()
+	a.settled[hex.EncodeToString(inv.RHash)] = inv
+	advanced := inv.SettleIndex > a.settleIndex
+	if advanced {
+		a.settleIndex = inv.SettleIndex
+	}
+	a.mu.Unlock()
+	if !advanced {
+		return
+	}
+
+	if err := a.store.SaveCursor(models.ChainLightning, chains.Cursor{Height: inv.SettleIndex}); err != nil {
+		log.Printf("Error saving Lightning settle index: %v", err)
+	}
+}
+
+// CheckPayment reports the deposit's invoice once it has been settled.
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	a.mu.Lock()
+	inv, ok := a.settled[deposit.Reference]
+	a.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	return &models.Transaction{
+		Chain:       models.ChainLightning,
+		TxHash:      deposit.Reference,
+		ToAddress:   deposit.Address,
+		Amount:      decimal.New(inv.AmtPaidMsat, -3-satoshiDecimals),
+		Token:       models.TokenNative,
+		BlockNumber: inv.SettleIndex,
+	}, nil
+}
+
+// Confirmations reports a settled invoice as final right away; settlement
+// cannot be reversed. A cancelled invoice is reported as dropped.
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	inv, err := a.lnd.lookupInvoice(ctx, tx.TxHash)
+	if err != nil {
+		return 0, err
+	}
+
+	switch inv.State {
+	case "SETTLED":
+		return a.RequiredConfirmations(), nil
+	case "CANCELED":
+		return 0, chains.ErrTransactionDropped
+	default:
+		return 0, nil
+	}
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return 1
+}
+
+func (a *Adapter) PollInterval() time.Duration {
+	return pollInterval
+}
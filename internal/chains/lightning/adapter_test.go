@@ -0,0 +1,289 @@
+package lightning
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const testMacaroon = "0201036c6e64"
+
+// fakeLND is a stand-in for LND's REST interface. The invoice subscription
+// replays the settlements in updates after the requested settle index and
+// then stays open.
+type fakeLND struct {
+	t    *testing.T
+	done chan struct{}
+
+	mu          sync.Mutex
+	invoices    map[string]*invoice // keyed by hex payment hash
+	updates     []*invoice
+	added       []addInvoiceRequest
+	settleIndex chan uint64
+}
+
+func newFakeLND(t *testing.T, invoices ...*invoice) (*fakeLND, *Adapter, *chaintest.Store) {
+	t.Helper()
+	lnd := &fakeLND{
+		t:           t,
+		done:        make(chan struct{}),
+		invoices:    make(map[string]*invoice),
+		settleIndex: make(chan uint64, 1),
+	}
+	for _, inv := range invoices {
+		lnd.invoices[hex.EncodeToString(inv.RHash)] = inv
+	}
+
+	server := httptest.NewServer(lnd)
+	t.Cleanup(server.Close)
+	// Runs before server.Close, which waits for the open subscription
+	t.Cleanup(func() { close(lnd.done) })
+
+	store := chaintest.NewStore()
+	a, err := New(Config{RESTURL: server.URL, Macaroon: testMacaroon}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lnd, a, store
+}
+
+func (f *fakeLND) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Grpc-Metadata-macaroon") != testMacaroon {
+		http.Error(w, `{"message":"verification failed"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/v1/invoices":
+		var req addInvoiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Errorf("decoding invoice request: %v", err)
+		}
+		f.mu.Lock()
+		f.added = append(f.added, req)
+		hash := []byte{byte(len(f.added)), 0xab}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(addInvoiceResponse{RHash: hash, PaymentRequest: "lnbc1500u1fake"})
+
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/v1/invoice/"):
+		f.mu.Lock()
+		inv, ok := f.invoices[strings.TrimPrefix(r.URL.Path, "/v1/invoice/")]
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"message":"unable to locate invoice"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(inv)
+
+	case r.Method == "GET" && r.URL.Path == "/v1/invoices/subscribe":
+		settleIndex, _ := strconv.ParseUint(r.URL.Query().Get("settle_index"), 10, 64)
+		f.settleIndex <- settleIndex
+
+		f.mu.Lock()
+		for _, inv := range f.updates {
+			if inv.SettleIndex > settleIndex {
+				json.NewEncoder(w).Encode(map[string]interface{}{"result": inv})
+			}
+		}
+		f.mu.Unlock()
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+		case <-f.done:
+		}
+
+	default:
+		f.t.Errorf("unexpected call to %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func settled(hash byte, settleIndex uint64, paidMsat int64) *invoice {
+	return &invoice{RHash: []byte{hash}, State: "SETTLED", SettleIndex: settleIndex, AmtPaidMsat: paidMsat}
+}
+
+func TestNewInvoice(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		expiresIn time.Duration
+		wantSats  int64
+		wantErr   bool
+	}{
+		{name: "whole satoshis", amount: "0.0015", expiresIn: time.Hour, wantSats: 150_000},
+		{name: "rounded up", amount: "0.000000015", expiresIn: time.Hour, wantSats: 2},
+		{name: "zero", amount: "0", expiresIn: time.Hour, wantErr: true},
+		{name: "expired", amount: "0.0015", expiresIn: -time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lnd, a, _ := newFakeLND(t)
+
+			invoice, err := a.NewInvoice(context.Background(), chains.InvoiceRequest{
+				PaymentID: "p1",
+				Amount:    decimal.RequireFromString(tt.amount),
+				ExpiresAt: time.Now().Add(tt.expiresIn),
+			})
+			if tt.wantErr {
+				if err == nil || len(lnd.added) != 0 {
+					t.Fatalf("NewInvoice() = %+v, want an error before reaching LND", invoice)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if invoice.Request != "lnbc1500u1fake" || invoice.Reference != "01ab" {
+				t.Errorf("NewInvoice() = %+v", invoice)
+			}
+			req := lnd.added[0]
+			if req.Value != tt.wantSats || req.Memo != "Payment p1" {
+				t.Errorf("invoice request = %+v, want %d sats", req, tt.wantSats)
+			}
+			if req.Expiry <= 0 || req.Expiry > int64(tt.expiresIn.Seconds()) {
+				t.Errorf("expiry = %ds, want at most %s", req.Expiry, tt.expiresIn)
+			}
+		})
+	}
+}
+
+func TestSettledInvoices(t *testing.T) {
+	lnd, a, store := newFakeLND(t,
+		settled(1, 5, 150_000_000), // settled while the gateway was down
+		&invoice{RHash: []byte{2}, State: "OPEN"},
+		&invoice{RHash: []byte{3}, State: "OPEN"},
+	)
+	lnd.updates = []*invoice{
+		settled(9, 6, 100_000),     // already processed before the restart
+		settled(2, 8, 200_000_000), // settled once subscribed
+		settled(4, 9, 100_000),     // not a payment of this gateway
+	}
+	store.SaveCursor(models.ChainLightning, chains.Cursor{Height: 7})
+
+	deposits := []chains.Deposit{
+		{Reference: "01", Token: chains.Token{Token: models.TokenNative}},
+		{Reference: "02", Token: chains.Token{Token: models.TokenNative}},
+		{Reference: "03", Token: chains.Token{Token: models.TokenNative}},
+	}
+	if err := a.Sync(context.Background(), deposits); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case settleIndex := <-lnd.settleIndex:
+		if settleIndex != 7 {
+			t.Errorf("subscribed from settle index %d, want the persisted 7", settleIndex)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("invoice subscription not started")
+	}
+
+	// The subscription delivers asynchronously
+	check := func(deposit chains.Deposit) *models.Transaction {
+		t.Helper()
+		tx, err := a.CheckPayment(context.Background(), deposit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for check(deposits[1]) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("streamed settlement not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		deposit chains.Deposit
+		want    string
+	}{
+		{deposits[0], "0.0015"},
+		{deposits[1], "0.002"},
+		{deposits[2], ""},
+	}
+	for _, tt := range tests {
+		tx := check(tt.deposit)
+		if tt.want == "" {
+			if tx != nil {
+				t.Errorf("CheckPayment(%s) = %+v, want none", tt.deposit.Reference, tx)
+			}
+			continue
+		}
+		if tx == nil || tx.TxHash != tt.deposit.Reference || !tx.Amount.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("CheckPayment(%s) = %+v, want %s BTC", tt.deposit.Reference, tx, tt.want)
+		}
+	}
+
+	// The newest settle index is persisted to resume from after a restart
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		cursor, _ := store.LoadCursor(models.ChainLightning)
+		if cursor.Height == 9 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("settle index cursor = %d, want 9", cursor.Height)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Settlements of payments no longer awaited are forgotten
+	if err := a.Sync(context.Background(), deposits[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if tx := check(deposits[0]); tx != nil {
+		t.Errorf("CheckPayment() = %+v after the payment is no longer awaited", tx)
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	tests := []struct {
+		name    string
+		invoice *invoice
+		want    int
+		wantErr error
+	}{
+		{name: "settled", invoice: settled(1, 5, 1000), want: 1},
+		{name: "open", invoice: &invoice{RHash: []byte{1}, State: "OPEN"}},
+		{name: "accepted", invoice: &invoice{RHash: []byte{1}, State: "ACCEPTED"}},
+		{name: "cancelled", invoice: &invoice{RHash: []byte{1}, State: "CANCELED"}, wantErr: chains.ErrTransactionDropped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, a, _ := newFakeLND(t, tt.invoice)
+
+			got, err := a.Confirmations(context.Background(), &models.Transaction{TxHash: "01"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirmations() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Confirmations() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// An unknown invoice is an LND error rather than a drop
+	_, a, _ := newFakeLND(t)
+	if _, err := a.Confirmations(context.Background(), &models.Transaction{TxHash: "01"}); err == nil || errors.Is(err, chains.ErrTransactionDropped) {
+		t.Errorf("Confirmations() error = %v, want an LND error", err)
+	}
+}
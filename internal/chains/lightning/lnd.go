@@ -0,0 +1,173 @@
+package lightning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// lndClient is a minimal client of LND's REST interface.
+type lndClient struct {
+	baseURL  string
+	macaroon string
+	http     *http.Client
+	stream   *http.Client
+}
+
+func newLNDClient(baseURL, macaroon, tlsCertPath string) (*lndClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsCertPath != "" {
+		// LND serves a self-signed certificate
+		pem, err := os.ReadFile(tlsCertPath)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", tlsCertPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &lndClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		macaroon: macaroon,
+		http:     &http.Client{Timeout: 15 * time.Second, Transport: transport},
+		stream:   &http.Client{Transport: transport}, // no timeout on the long-lived stream
+	}, nil
+}
+
+func (c *lndClient) request(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.macaroon != "" {
+		req.Header.Set("Grpc-Metadata-macaroon", c.macaroon)
+	}
+	return req, nil
+}
+
+func (c *lndClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	req, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("lnd returned status %d for %s: %s", resp.StatusCode, path, bytes.TrimSpace(message))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// invoice holds the fields of lnrpc.Invoice the gateway uses. LND encodes
+// 64-bit integers as JSON strings and byte fields as base64.
+type invoice struct {
+	RHash          []byte `json:"r_hash"`
+	PaymentRequest string `json:"payment_request"`
+	State          string `json:"state"`
+	AmtPaidMsat    int64  `json:"amt_paid_msat,string"`
+	SettleIndex    uint64 `json:"settle_index,string"`
+}
+
+type addInvoiceRequest struct {
+	Memo   string `json:"memo"`
+	Value  int64  `json:"value,string"`
+	Expiry int64  `json:"expiry,string"`
+}
+
+type addInvoiceResponse struct {
+	RHash          []byte `json:"r_hash"`
+	PaymentRequest string `json:"payment_request"`
+}
+
+func (c *lndClient) addInvoice(ctx context.Context, req addInvoiceRequest) (*addInvoiceResponse, error) {
+	var result addInvoiceResponse
+	if err := c.do(ctx, "POST", "/v1/invoices", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// lookupInvoice fetches an invoice by its hex-encoded payment hash.
+func (c *lndClient) lookupInvoice(ctx context.Context, paymentHash string) (*invoice, error) {
+	var result invoice
+	if err := c.do(ctx, "GET", "/v1/invoice/"+paymentHash, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// subscribeInvoices streams invoice updates to handle until the stream
+// breaks or ctx is cancelled. Settlements after settleIndex are replayed
+// first, so none are missed across reconnects.
+func (c *lndClient) subscribeInvoices(ctx context.Context, settleIndex uint64, handle func(*invoice)) error {
+	req, err := c.request(ctx, "GET", fmt.Sprintf("/v1/invoices/subscribe?settle_index=%d", settleIndex), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lnd returned status %d for the invoice subscription", resp.StatusCode)
+	}
+
+	// The stream is a sequence of {"result": invoice} or {"error": ...}
+	// objects, one per line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var message struct {
+			Result *invoice `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return fmt.Errorf("lnd invoice subscription: %s", message.Error.Message)
+		}
+		if message.Result != nil {
+			handle(message.Result)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,46 @@
+package chains
+
+import (
+	"fmt"
+	"multi-chain-payment-gateway/internal/models"
+)
+
+// Registry holds the adapters of all enabled chains, in registration order.
+type Registry struct {
+	adapters map[models.Chain]ChainAdapter
+	order    []models.Chain
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		adapters: make(map[models.Chain]ChainAdapter),
+	}
+}
+
+// Register adds an adapter, replacing any previously registered for the
+// same chain.
+func (r *Registry) Register(adapter ChainAdapter) {
+	chain := adapter.Chain()
+	if _, exists := r.adapters[chain]; !exists {
+		r.order = append(r.order, chain)
+	}
+	r.adapters[chain] = adapter
+}
+
+// Get returns the adapter for chain.
+func (r *Registry) Get(chain models.Chain) (ChainAdapter, error) {
+	adapter, ok := r.adapters[chain]
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain: %s", chain)
+	}
+	return adapter, nil
+}
+
+// Adapters returns all registered adapters in registration order.
+func (r *Registry) Adapters() []ChainAdapter {
+	adapters := make([]ChainAdapter, 0, len(r.order))
+	for _, chain := range r.order {
+		adapters = append(adapters, r.adapters[chain])
+	}
+	return adapters
+}
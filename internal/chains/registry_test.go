@@ -0,0 +1,75 @@
+package chains
+
+import (
+	"context"
+	"multi-chain-payment-gateway/internal/models"
+	"testing"
+)
+
+// stubAdapter is a ChainAdapter doing nothing for a chain.
+type stubAdapter struct {
+	chain  models.Chain
+	tokens []Token
+}
+
+func (a *stubAdapter) Chain() models.Chain                        { return a.chain }
+func (a *stubAdapter) NewAddress(AddressRequest) (*Wallet, error) { return nil, nil }
+func (a *stubAdapter) ValidateAddress(string) error               { return nil }
+func (a *stubAdapter) Tokens() []Token                            { return a.tokens }
+func (a *stubAdapter) Sync(context.Context, []Deposit) error      { return nil }
+func (a *stubAdapter) CheckPayment(context.Context, Deposit) (*models.Transaction, error) {
+	return nil, nil
+}
+func (a *stubAdapter) Confirmations(context.Context, *models.Transaction) (int, error) {
+	return 0, nil
+}
+func (a *stubAdapter) RequiredConfirmations() int { return 1 }
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	ethereum := &stubAdapter{chain: models.ChainEthereum}
+	solana := &stubAdapter{chain: models.ChainSolana}
+	replacement := &stubAdapter{chain: models.ChainEthereum}
+
+	registry.Register(ethereum)
+	registry.Register(solana)
+	registry.Register(replacement)
+
+	adapters := registry.Adapters()
+	if len(adapters) != 2 || adapters[0] != replacement || adapters[1] != solana {
+		t.Errorf("Adapters() = %v, want the replacement then Solana in registration order", adapters)
+	}
+
+	tests := []struct {
+		chain models.Chain
+		want  ChainAdapter
+	}{
+		{models.ChainEthereum, replacement},
+		{models.ChainSolana, solana},
+		{models.ChainTON, nil},
+	}
+	for _, tt := range tests {
+		got, err := registry.Get(tt.chain)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("Get(%s) succeeded for an unregistered chain", tt.chain)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Get(%s) = %v, %v", tt.chain, got, err)
+		}
+	}
+}
+
+func TestFindToken(t *testing.T) {
+	usdc := Token{Token: models.TokenUSDC, Symbol: "USDC", Decimals: 6, Contract: "0xc0"}
+	adapter := &stubAdapter{tokens: []Token{{Token: models.TokenNative, Symbol: "ETH", Decimals: 18}, usdc}}
+
+	if got, ok := FindToken(adapter, models.TokenUSDC); !ok || got != usdc {
+		t.Errorf("FindToken(usdc) = %v, %v", got, ok)
+	}
+	if _, ok := FindToken(adapter, models.TokenUSDT); ok {
+		t.Error("found a token the adapter does not accept")
+	}
+}
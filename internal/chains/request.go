@@ -0,0 +1,38 @@
+package chains
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidPayer is returned when a payment transaction is requested for
+// an account that is not a valid address on the chain.
+var ErrInvalidPayer = errors.New("invalid payer account")
+
+// PaymentRequester is implemented by adapters whose wallets understand a
+// payment request URI, such as Solana Pay's solana: or EIP-681's
+// ethereum: scheme, so a single scan fills in recipient, token and amount.
+type PaymentRequester interface {
+	PaymentURI(req PaymentRequest) (string, error)
+}
+
+// TransactionRequester is implemented by adapters that can build the
+// payment transaction for the wallet paying it, as Solana Pay transaction
+// requests do. The transaction is returned unsigned, in wire encoding.
+type TransactionRequester interface {
+	PaymentTransaction(ctx context.Context, req PaymentRequest, payer string) ([]byte, error)
+}
+
+// PaymentRequest is the transfer a payment request asks a wallet to make.
+// Adapters include the optional fields their scheme supports.
+type PaymentRequest struct {
+	Address string
+	Token   Token
+	Amount  decimal.Decimal
+
+	Reference string // e.g. a Solana Pay reference key
+	Label     string // the merchant's name
+	Memo      string // recorded on chain with the transfer
+}
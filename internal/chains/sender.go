@@ -0,0 +1,78 @@
+package chains
+
+import (
+	"context"
+	"math/big"
+	"multi-chain-payment-gateway/internal/models"
+)
+
+// Sender is implemented by adapters that can move funds out of addresses
+// the gateway holds the keys of, e.g. to sweep deposits to a treasury.
+// Private keys are in the form the adapter's NewAddress returns them.
+type Sender interface {
+	// AddressOf returns the address controlled by privateKey.
+	AddressOf(privateKey string) (string, error)
+
+	// Balance returns address's balance of token in base units.
+	Balance(ctx context.Context, address string, token Token) (*big.Int, error)
+
+	// TransferFee estimates the native fee, in base units, its sender pays
+	// for a transfer of token.
+	TransferFee(ctx context.Context, token Token) (*big.Int, error)
+
+	// Transfer signs and broadcasts a transfer and returns its hash.
+	Transfer(ctx context.Context, req TransferRequest) (string, error)
+
+	// TransferStatus reports how a broadcast transfer has fared.
+	TransferStatus(ctx context.Context, txHash string) (TransferStatus, error)
+}
+
+// FeeSponsor is implemented by senders whose transfers can have their fees
+// paid by another account, TransferRequest.FeePayerKey, so the sending
+// address needs no native balance.
+type FeeSponsor interface {
+	SponsorsFees() bool
+}
+
+// TransferTracker is implemented by senders that manage their transfers
+// after broadcast, replacing ones stuck unconfirmed with higher fees.
+// Their state lives in the transfer's record, which Transfer fills in and
+// TrackTransfer updates; the caller persists it after each call.
+type TransferTracker interface {
+	// TrackTransfer reports how the transfer has fared, possibly
+	// rebroadcasting it signed with privateKey, the sending address's key.
+	// record.TxHash is updated if a replacement is broadcast or mined.
+	TrackTransfer(ctx context.Context, record *models.OutgoingTransaction, privateKey string) (TransferStatus, error)
+}
+
+// ForwarderFlusher is implemented by adapters whose deposit addresses can
+// be counterfactual forwarder contracts, created by a factory from a salt
+// and forwarding funds to the treasury they were built for.
+type ForwarderFlusher interface {
+	// FlushForwarders deploys the forwarders of salts where needed and moves
+	// their balances of token on in a single transaction, paid for by
+	// privateKey. It returns the transaction's hash and fills in record
+	// like Transfer.
+	FlushForwarders(ctx context.Context, privateKey string, token Token, salts []string, record *models.OutgoingTransaction) (string, error)
+}
+
+// TransferRequest describes an outgoing transfer.
+type TransferRequest struct {
+	PrivateKey  string // key of the sending address
+	FeePayerKey string // optional, for FeeSponsor senders
+	To          string
+	Token       Token
+	Amount      *big.Int // base units
+
+	// Record is the transfer's record, for TransferTracker senders.
+	Record *models.OutgoingTransaction
+}
+
+// TransferStatus is the state of a broadcast transfer.
+type TransferStatus int
+
+const (
+	TransferPending TransferStatus = iota
+	TransferSucceeded
+	TransferFailed
+)
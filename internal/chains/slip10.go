@@ -0,0 +1,63 @@
+package chains
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// hardenedOffset marks a hardened derivation index.
+const hardenedOffset = 0x80000000
+
+// DeriveEd25519 derives an ed25519 key from a BIP39 seed along a SLIP-0010
+// path such as m/44'/501'/0'. Ed25519 only supports hardened derivation, so
+// every path component must be hardened.
+func DeriveEd25519(seed []byte, path string) (ed25519.PrivateKey, error) {
+	indexes, err := parseHardenedPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	for _, index := range indexes {
+		data := make([]byte, 0, 37)
+		data = append(data, 0)
+		data = append(data, key...)
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return ed25519.NewKeyFromSeed(key), nil
+}
+
+func parseHardenedPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		if !strings.HasSuffix(part, "'") {
+			return nil, fmt.Errorf("ed25519 path component %q must be hardened", part)
+		}
+		index, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: %w", path, err)
+		}
+		indexes = append(indexes, uint32(index)+hardenedOffset)
+	}
+	return indexes, nil
+}
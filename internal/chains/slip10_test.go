@@ -0,0 +1,46 @@
+package chains
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// SLIP-0010 test vector 1 for ed25519.
+func TestDeriveEd25519(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path    string
+		private string
+		public  string
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", "8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", "1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187"},
+		{"m/0'/1'/2'", "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9", "ae98736566d30ed0e9d2f4486a64bc95740d89c7db33f52121f8ea8f76ff0fc1"},
+		{"m/0'/1'/2'/2'", "30d1dc7e5fc04c31219ab25a27ae00b50f6fd66622f6e9c913253d6511d1e662", "8abae2d66361c879b900d204ad2cc4984fa2aa344dd7ddc46007329ac76c429c"},
+		{"m/0'/1'/2'/2'/1000000000'", "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793", "3c24da049451555d51a7014a37337aa4e12d41e485abccfa46b47dfb2af54b7a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			key, err := DeriveEd25519(seed, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(key.Seed()); got != tt.private {
+				t.Errorf("private key = %s, want %s", got, tt.private)
+			}
+			if got := hex.EncodeToString(key[32:]); got != tt.public {
+				t.Errorf("public key = %s, want %s", got, tt.public)
+			}
+		})
+	}
+}
+
+func TestDeriveEd25519RejectsInvalidPaths(t *testing.T) {
+	for _, path := range []string{"", "44'/501'", "m/44'/501'/0", "m/x'", "m/2147483648'"} {
+		if _, err := DeriveEd25519(make([]byte, 64), path); err == nil {
+			t.Errorf("DeriveEd25519(%q) succeeded", path)
+		}
+	}
+}
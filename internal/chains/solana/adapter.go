@@ -0,0 +1,390 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/shopspring/decimal"
+)
+
+// signatureLimit bounds how many recent signatures are inspected per
+// deposit address on each check.
+const signatureLimit = 25
+
+// finalizedConfirmations is what the RPC's "finalized" status amounts to;
+// getSignatureStatuses stops counting confirmations at that point.
+const finalizedConfirmations = 32
+
+// derivationPath is the SLIP-0010 path deposit keys are derived at.
+const derivationPath = "m/44'/501'/%d'"
+
+// Config configures the Solana adapter.
+type Config struct {
+	RPCURL        string
+	Seed          string // hex-encoded BIP39 seed; random keys if empty
+	Tokens        []chains.Token
+	Confirmations int
+
+	// MerchantAddress, if set, receives every payment in place of derived
+	// or random addresses. Payments are told apart by a Solana Pay
+	// reference, a random public key the payer adds to the transfer.
+	MerchantAddress string
+}
+
+// Adapter accepts SOL and SPL stablecoin payments on Solana.
+type Adapter struct {
+	config Config
+	store  chains.Store
+	rpc    *rpcClient
+	seed   []byte
+
+	// seen caches fetched transactions per deposit (see cacheKey), so each
+	// signature is only fetched once while its payment is pending.
+	mu   sync.Mutex
+	seen map[string]map[string]*transaction
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	a := &Adapter{
+		config: cfg,
+		store:  store,
+		rpc:    newRPCClient(cfg.RPCURL),
+		seen:   make(map[string]map[string]*transaction),
+	}
+
+	if cfg.Seed != "" {
+		seed, err := hex.DecodeString(cfg.Seed)
+		if err != nil || len(seed) < 16 || len(seed) > 64 {
+			return nil, fmt.Errorf("invalid SOLANA_SEED: expected 16 to 64 hex-encoded bytes")
+		}
+		a.seed = seed
+	}
+	if cfg.MerchantAddress != "" {
+		if _, err := DecodePublicKey(cfg.MerchantAddress); err != nil {
+			return nil, fmt.Errorf("invalid SOLANA_MERCHANT_ADDRESS: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return models.ChainSolana
+}
+
+// NewAddress hands out the merchant address, or a derived or random one,
+// with a fresh Solana Pay reference. Payments to the merchant address are
+// told apart by it; on other addresses it only lets wallets and explorers
+// find the payment.
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	var wallet *chains.Wallet
+	var err error
+	switch {
+	case a.config.MerchantAddress != "":
+		wallet = &chains.Wallet{Address: a.config.MerchantAddress, Chain: models.ChainSolana}
+	case a.seed != nil:
+		wallet, err = a.deriveAddress()
+	default:
+		wallet, err = randomAddress()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Any 32 bytes make a valid reference; it never has to sign
+	reference := make([]byte, ed25519.PublicKeySize)
+	if _, err := rand.Read(reference); err != nil {
+		return nil, err
+	}
+	wallet.Reference = base58.Encode(reference)
+	return wallet, nil
+}
+
+func randomAddress() (*chains.Wallet, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:    base58.Encode(publicKey),
+		PrivateKey: hex.EncodeToString(privateKey),
+		Chain:      models.ChainSolana,
+	}, nil
+}
+
+// DerivesPublicly reports whether payments go to the merchant address, so
+// no deposit keys are ever created.
+func (a *Adapter) DerivesPublicly() bool {
+	return a.config.MerchantAddress != ""
+}
+
+func (a *Adapter) deriveAddress() (*chains.Wallet, error) {
+	index, err := a.store.NextIndex(string(models.ChainSolana))
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf(derivationPath, index)
+	privateKey, err := chains.DeriveEd25519(a.seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:         base58.Encode(privateKey.Public().(ed25519.PublicKey)),
+		PrivateKey:      hex.EncodeToString(privateKey),
+		Chain:           models.ChainSolana,
+		DerivationIndex: index,
+		DerivationPath:  path,
+	}, nil
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	if _, err := DecodePublicKey(address); err != nil {
+		return fmt.Errorf("invalid Solana address %q: %w", address, err)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+// Sync drops cached transactions of deposits that are no longer awaited.
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	watched := make(map[string]bool, len(deposits))
+	for _, deposit := range deposits {
+		watched[a.cacheKey(deposit)] = true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.seen {
+		if !watched[key] {
+			delete(a.seen, key)
+		}
+	}
+	return nil
+}
+
+// byReference reports whether deposit is looked up by its reference: only
+// deposits to the shared merchant address are, as payers sending to a
+// deposit address of their own by hand leave the reference out.
+func (a *Adapter) byReference(deposit chains.Deposit) bool {
+	return deposit.Reference != "" && deposit.Address == a.config.MerchantAddress
+}
+
+// cacheKey is what a deposit's transactions are cached under. Deposits to
+// the merchant address share it, so they go by their reference.
+func (a *Adapter) cacheKey(deposit chains.Deposit) string {
+	if a.byReference(deposit) {
+		return deposit.Reference
+	}
+	return deposit.Address
+}
+
+// CheckPayment looks through the deposit's recent transactions for one that
+// credits it with at least the expected amount. SOL is watched on the
+// deposit address itself, SPL tokens on its associated token account.
+// Deposits to the merchant address are looked up by their reference
+// instead, as only the payment's own transfer lists it among its accounts.
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	watched := deposit.Address
+	var tokenAccount string
+	if deposit.Token.Token != models.TokenNative {
+		if deposit.Token.Contract == "" {
+			return nil, nil
+		}
+
+		var err error
+		tokenAccount, err = AssociatedTokenAddress(deposit.Address, deposit.Token.Contract)
+		if err != nil {
+			return nil, err
+		}
+		watched = tokenAccount
+	}
+	if a.byReference(deposit) {
+		watched = deposit.Reference
+	}
+
+	signatures, err := a.rpc.getSignaturesForAddress(ctx, watched, signatureLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := deposit.Amount.Shift(int32(deposit.Token.Decimals))
+	for _, info := range signatures {
+		if failed(info.Err) {
+			continue
+		}
+
+		tx, err := a.fetchTransaction(ctx, a.cacheKey(deposit), info.Signature)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil || tx.Meta == nil || failed(tx.Meta.Err) {
+			continue
+		}
+
+		var transfer *models.Transaction
+		if deposit.Token.Token == models.TokenNative {
+			transfer = nativeTransfer(tx, deposit.Address)
+		} else {
+			transfer = tokenTransfer(tx, deposit.Address, tokenAccount, deposit.Token.Contract)
+		}
+		if transfer == nil || transfer.Amount.LessThan(expected) {
+			continue
+		}
+
+		transfer.Chain = models.ChainSolana
+		transfer.TxHash = info.Signature
+		transfer.Token = deposit.Token.Token
+		transfer.Amount = transfer.Amount.Shift(-int32(deposit.Token.Decimals))
+		transfer.BlockNumber = tx.Slot
+		return transfer, nil
+	}
+
+	return nil, nil
+}
+
+// nativeTransfer returns the SOL credited to address by tx, in lamports.
+func nativeTransfer(tx *transaction, address string) *models.Transaction {
+	keys := tx.accountKeys()
+	for i, key := range keys {
+		if key != address {
+			continue
+		}
+		if i >= len(tx.Meta.PreBalances) || i >= len(tx.Meta.PostBalances) {
+			return nil
+		}
+
+		pre := new(big.Int).SetUint64(tx.Meta.PreBalances[i])
+		received := new(big.Int).SetUint64(tx.Meta.PostBalances[i])
+		received.Sub(received, pre)
+		if received.Sign() <= 0 {
+			return nil
+		}
+
+		return &models.Transaction{
+			FromAddress: keys[0],
+			ToAddress:   address,
+			Amount:      decimal.NewFromBigInt(received, 0),
+		}
+	}
+	return nil
+}
+
+// tokenTransfer returns the mint tokens credited to tokenAccount by tx, in
+// base units, using the balance snapshots the RPC attaches to transactions.
+func tokenTransfer(tx *transaction, owner, tokenAccount, mint string) *models.Transaction {
+	keys := tx.accountKeys()
+	pre := tokenBalances(tx.Meta.PreTokenBalances, keys, mint)
+	post := tokenBalances(tx.Meta.PostTokenBalances, keys, mint)
+
+	received := new(big.Int).Sub(amountOf(post, tokenAccount), amountOf(pre, tokenAccount))
+	if received.Sign() <= 0 {
+		return nil
+	}
+
+	// The sender is the owner of the account whose balance went down
+	from := keys[0]
+	for account, before := range pre {
+		if account != tokenAccount && amountOf(post, account).Cmp(before.amount) < 0 && before.owner != "" {
+			from = before.owner
+			break
+		}
+	}
+
+	return &models.Transaction{
+		FromAddress:   from,
+		ToAddress:     owner,
+		Amount:        decimal.NewFromBigInt(received, 0),
+		TokenAccount:  tokenAccount,
+		TokenContract: mint,
+	}
+}
+
+type accountBalance struct {
+	owner  string
+	amount *big.Int
+}
+
+// tokenBalances indexes the balances of mint by token account address.
+// Accounts missing from a snapshot have a zero balance.
+func tokenBalances(balances []tokenBalance, keys []string, mint string) map[string]accountBalance {
+	result := make(map[string]accountBalance)
+	for _, balance := range balances {
+		if balance.Mint != mint || balance.AccountIndex >= len(keys) {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(balance.UITokenAmount.Amount, 10)
+		if !ok {
+			continue
+		}
+		result[keys[balance.AccountIndex]] = accountBalance{owner: balance.Owner, amount: amount}
+	}
+	return result
+}
+
+// amountOf returns account's balance, treating a missing entry as zero.
+func amountOf(balances map[string]accountBalance, account string) *big.Int {
+	if balance, ok := balances[account]; ok {
+		return balance.amount
+	}
+	return new(big.Int)
+}
+
+func (a *Adapter) fetchTransaction(ctx context.Context, key, signature string) (*transaction, error) {
+	a.mu.Lock()
+	tx, ok := a.seen[key][signature]
+	a.mu.Unlock()
+	if ok {
+		return tx, nil
+	}
+
+	tx, err := a.rpc.getTransaction(ctx, signature)
+	if err != nil || tx == nil {
+		// Not cached: a just-confirmed transaction may not be served yet
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if a.seen[key] == nil {
+		a.seen[key] = make(map[string]*transaction)
+	}
+	a.seen[key][signature] = tx
+	a.mu.Unlock()
+	return tx, nil
+}
+
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	status, err := a.rpc.getSignatureStatus(ctx, tx.TxHash)
+	if err != nil {
+		return 0, err
+	}
+	if status == nil || failed(status.Err) {
+		return 0, chains.ErrTransactionDropped
+	}
+
+	tx.BlockNumber = status.Slot
+	if status.ConfirmationStatus == "finalized" || status.Confirmations == nil {
+		if a.config.Confirmations > finalizedConfirmations {
+			return a.config.Confirmations, nil
+		}
+		return finalizedConfirmations, nil
+	}
+	return *status.Confirmations, nil
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return a.config.Confirmations
+}
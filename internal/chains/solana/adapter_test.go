@@ -0,0 +1,454 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/shopspring/decimal"
+)
+
+const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+
+var (
+	sol  = chains.Token{Token: models.TokenNative, Symbol: "SOL", Decimals: 9}
+	usdc = chains.Token{Token: models.TokenUSDC, Symbol: "USDC", Decimals: 6, Contract: usdcMint}
+
+	depositAddress = testKey(1)
+	payer          = testKey(2)
+	reference      = testKey(3)
+)
+
+// testKey returns a deterministic ed25519 public key.
+func testKey(n byte) string {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = n
+	return base58.Encode(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+}
+
+// fakeRPC answers the JSON-RPC methods a test registers.
+type fakeRPC struct {
+	t        *testing.T
+	mu       sync.Mutex
+	handlers map[string]func(params []json.RawMessage) interface{}
+}
+
+func newTestAdapter(t *testing.T, cfg Config) (*Adapter, *fakeRPC) {
+	t.Helper()
+	rpc := &fakeRPC{t: t, handlers: make(map[string]func([]json.RawMessage) interface{})}
+	server := httptest.NewServer(rpc)
+	t.Cleanup(server.Close)
+
+	cfg.RPCURL = server.URL
+	cfg.Tokens = []chains.Token{sol, usdc}
+	a, err := New(cfg, chaintest.NewStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, rpc
+}
+
+func (f *fakeRPC) handle(method string, fn func(params []json.RawMessage) interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = fn
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	handler, ok := f.handlers[req.Method]
+	f.mu.Unlock()
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if ok {
+		response["result"] = handler(req.Params)
+	} else {
+		f.t.Errorf("unexpected RPC call %s", req.Method)
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func stringParam(t *testing.T, params []json.RawMessage) string {
+	t.Helper()
+	var s string
+	if err := json.Unmarshal(params[0], &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// solTransfer is a transaction moving lamports from payer to
+// depositAddress, with reference as an extra read-only account.
+func solTransfer(lamports uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"slot": 300,
+		"meta": map[string]interface{}{
+			"err":               nil,
+			"preBalances":       []uint64{5_000_000_000, 0, 1, 0},
+			"postBalances":      []uint64{5_000_000_000 - lamports - 5000, lamports, 1, 0},
+			"preTokenBalances":  []interface{}{},
+			"postTokenBalances": []interface{}{},
+		},
+		"transaction": map[string]interface{}{
+			"signatures": []string{"sig"},
+			"message": map[string]interface{}{
+				"accountKeys": []string{payer, depositAddress, SystemProgramID, reference},
+			},
+		},
+	}
+}
+
+// tokenTransferTx is a TransferChecked of units of mint from payer's token
+// account to the deposit's, whose balances are listed in the transaction.
+func tokenTransferTx(t *testing.T, mint string, units int64) map[string]interface{} {
+	source, err := AssociatedTokenAddress(payer, mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination, err := AssociatedTokenAddress(depositAddress, mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance := func(index int, owner string, amount int64) map[string]interface{} {
+		return map[string]interface{}{
+			"accountIndex":  index,
+			"mint":          mint,
+			"owner":         owner,
+			"uiTokenAmount": map[string]interface{}{"amount": decimal.NewFromInt(amount).String(), "decimals": 6},
+		}
+	}
+
+	return map[string]interface{}{
+		"slot": 301,
+		"meta": map[string]interface{}{
+			"err":          nil,
+			"preBalances":  []uint64{1, 1, 1, 1},
+			"postBalances": []uint64{1, 1, 1, 1},
+			// The destination account did not exist before
+			"preTokenBalances":  []interface{}{balance(1, payer, 100_000_000)},
+			"postTokenBalances": []interface{}{balance(1, payer, 100_000_000-units), balance(2, depositAddress, units)},
+		},
+		"transaction": map[string]interface{}{
+			"signatures": []string{"sig"},
+			"message": map[string]interface{}{
+				"accountKeys": []string{payer, source, destination, reference},
+			},
+		},
+	}
+}
+
+func TestCheckPayment(t *testing.T) {
+	otherMint := testKey(9)
+	failedTx := solTransfer(2_000_000_000)
+	failedTx["meta"].(map[string]interface{})["err"] = map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}
+
+	tests := []struct {
+		name      string
+		token     chains.Token
+		merchant  bool
+		reference string
+		tx        func(t *testing.T) map[string]interface{}
+		sigErr    interface{}
+		want      string
+	}{
+		{
+			name:  "SOL payment",
+			token: sol,
+			tx:    func(*testing.T) map[string]interface{} { return solTransfer(2_000_000_000) },
+			want:  "2",
+		},
+		{
+			name:  "SOL overpayment",
+			token: sol,
+			tx:    func(*testing.T) map[string]interface{} { return solTransfer(2_500_000_000) },
+			want:  "2.5",
+		},
+		{
+			name:  "SOL underpayment",
+			token: sol,
+			tx:    func(*testing.T) map[string]interface{} { return solTransfer(1_999_999_999) },
+		},
+		{
+			name:  "failed transaction",
+			token: sol,
+			tx:    func(*testing.T) map[string]interface{} { return failedTx },
+		},
+		{
+			name:   "failed signature",
+			token:  sol,
+			tx:     func(*testing.T) map[string]interface{} { return solTransfer(2_000_000_000) },
+			sigErr: map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}},
+		},
+		{
+			name:  "not yet served",
+			token: sol,
+			tx:    func(*testing.T) map[string]interface{} { return nil },
+		},
+		{
+			name:  "USDC payment to the token account",
+			token: usdc,
+			tx:    func(t *testing.T) map[string]interface{} { return tokenTransferTx(t, usdcMint, 2_000_000) },
+			want:  "2",
+		},
+		{
+			name:  "USDC underpayment",
+			token: usdc,
+			tx:    func(t *testing.T) map[string]interface{} { return tokenTransferTx(t, usdcMint, 1_999_999) },
+		},
+		{
+			name:  "transfer of another mint",
+			token: usdc,
+			tx:    func(t *testing.T) map[string]interface{} { return tokenTransferTx(t, otherMint, 2_000_000) },
+		},
+		{
+			name:      "SOL payment with a reference to a deposit address",
+			token:     sol,
+			reference: reference,
+			tx:        func(*testing.T) map[string]interface{} { return solTransfer(2_000_000_000) },
+			want:      "2",
+		},
+		{
+			name:      "SOL payment by reference",
+			token:     sol,
+			merchant:  true,
+			reference: reference,
+			tx:        func(*testing.T) map[string]interface{} { return solTransfer(2_000_000_000) },
+			want:      "2",
+		},
+		{
+			name:      "USDC payment by reference",
+			token:     usdc,
+			merchant:  true,
+			reference: reference,
+			tx:        func(t *testing.T) map[string]interface{} { return tokenTransferTx(t, usdcMint, 2_000_000) },
+			want:      "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if tt.merchant {
+				cfg.MerchantAddress = depositAddress
+			}
+			a, rpc := newTestAdapter(t, cfg)
+
+			// Signatures are looked up by the merchant payment's reference,
+			// the token account or the address, in that order
+			watched := depositAddress
+			if tt.token.Token != models.TokenNative {
+				watched, _ = AssociatedTokenAddress(depositAddress, usdcMint)
+			}
+			if tt.merchant {
+				watched = tt.reference
+			}
+			rpc.handle("getSignaturesForAddress", func(params []json.RawMessage) interface{} {
+				if got := stringParam(t, params); got != watched {
+					t.Errorf("signatures of %s, want %s", got, watched)
+				}
+				return []interface{}{map[string]interface{}{"signature": "sig", "slot": 300, "err": tt.sigErr}}
+			})
+			tx := tt.tx(t)
+			rpc.handle("getTransaction", func([]json.RawMessage) interface{} {
+				if tx == nil {
+					return nil
+				}
+				return tx
+			})
+
+			got, err := a.CheckPayment(context.Background(), chains.Deposit{
+				Address:   depositAddress,
+				Token:     tt.token,
+				Amount:    decimal.NewFromInt(2),
+				Reference: tt.reference,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("CheckPayment() = %+v, want none", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("payment not found")
+			}
+			if !got.Amount.Equal(decimal.RequireFromString(tt.want)) || got.FromAddress != payer || got.TxHash != "sig" {
+				t.Errorf("CheckPayment() = %+v, want %s from %s", got, tt.want, payer)
+			}
+		})
+	}
+}
+
+func TestCheckPaymentWithoutMint(t *testing.T) {
+	a, _ := newTestAdapter(t, Config{})
+	usdt := chains.Token{Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6}
+
+	// Nothing is looked up for a token whose mint is not configured
+	tx, err := a.CheckPayment(context.Background(), chains.Deposit{Address: depositAddress, Token: usdt, Amount: decimal.NewFromInt(2)})
+	if err != nil || tx != nil {
+		t.Errorf("CheckPayment() = %v, %v, want none", tx, err)
+	}
+}
+
+func TestCheckPaymentCachesTransactions(t *testing.T) {
+	a, rpc := newTestAdapter(t, Config{})
+	fetched := 0
+	rpc.handle("getSignaturesForAddress", func([]json.RawMessage) interface{} {
+		return []interface{}{map[string]interface{}{"signature": "sig", "slot": 300}}
+	})
+	rpc.handle("getTransaction", func([]json.RawMessage) interface{} {
+		fetched++
+		return solTransfer(1_000_000_000)
+	})
+
+	deposit := chains.Deposit{Address: depositAddress, Token: sol, Amount: decimal.NewFromInt(2)}
+	for i := 0; i < 3; i++ {
+		if tx, err := a.CheckPayment(context.Background(), deposit); err != nil || tx != nil {
+			t.Fatalf("CheckPayment() = %v, %v", tx, err)
+		}
+	}
+	if fetched != 1 {
+		t.Errorf("transaction fetched %d times, want once", fetched)
+	}
+
+	// Once the payment is no longer awaited its transactions are forgotten
+	a.Sync(context.Background(), nil)
+	a.CheckPayment(context.Background(), deposit)
+	if fetched != 2 {
+		t.Errorf("transaction fetched %d times after Sync, want twice", fetched)
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	five := 5
+	tests := []struct {
+		name     string
+		status   interface{}
+		required int
+		want     int
+		wantErr  error
+	}{
+		{
+			name:   "confirmed",
+			status: map[string]interface{}{"slot": 300, "confirmations": five, "confirmationStatus": "confirmed"},
+			want:   5,
+		},
+		{
+			name:   "finalized",
+			status: map[string]interface{}{"slot": 300, "confirmations": nil, "confirmationStatus": "finalized"},
+			want:   finalizedConfirmations,
+		},
+		{
+			name:     "finalized with more required",
+			status:   map[string]interface{}{"slot": 300, "confirmations": nil, "confirmationStatus": "finalized"},
+			required: 40,
+			want:     40,
+		},
+		{
+			name:    "failed",
+			status:  map[string]interface{}{"slot": 300, "err": map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}},
+			wantErr: chains.ErrTransactionDropped,
+		},
+		{
+			name:    "unknown",
+			status:  nil,
+			wantErr: chains.ErrTransactionDropped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, rpc := newTestAdapter(t, Config{Confirmations: tt.required})
+			rpc.handle("getSignatureStatuses", func([]json.RawMessage) interface{} {
+				return map[string]interface{}{"value": []interface{}{tt.status}}
+			})
+
+			tx := &models.Transaction{TxHash: "sig"}
+			got, err := a.Confirmations(context.Background(), tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirmations() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Confirmations() = %d, want %d", got, tt.want)
+			}
+			if tt.wantErr == nil && tx.BlockNumber != 300 {
+				t.Errorf("slot = %d, want 300", tx.BlockNumber)
+			}
+		})
+	}
+}
+
+func TestDeriveAddress(t *testing.T) {
+	seed := "000102030405060708090a0b0c0d0e0f"
+	a, err := New(Config{Seed: seed}, chaintest.NewStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index := 0; index < 2; index++ {
+		wallet, err := a.NewAddress(chains.AddressRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := hex.DecodeString(seed)
+		key, _ := chains.DeriveEd25519(raw, wallet.DerivationPath)
+		if want := base58.Encode(key.Public().(ed25519.PublicKey)); wallet.Address != want {
+			t.Errorf("address = %s, want %s", wallet.Address, want)
+		}
+		if want := fmt.Sprintf("m/44'/501'/%d'", index); wallet.DerivationPath != want {
+			t.Errorf("path = %s, want %s", wallet.DerivationPath, want)
+		}
+		if owner, err := a.AddressOf(wallet.PrivateKey); err != nil || owner != wallet.Address {
+			t.Errorf("AddressOf() = %s, %v, want %s", owner, err, wallet.Address)
+		}
+		// Derived addresses get a Solana Pay reference too
+		if _, err := DecodePublicKey(wallet.Reference); err != nil {
+			t.Errorf("reference %q is not a public key: %v", wallet.Reference, err)
+		}
+	}
+}
+
+func TestMerchantAddress(t *testing.T) {
+	a, _ := newTestAdapter(t, Config{MerchantAddress: depositAddress})
+	first, err := a.NewAddress(chains.AddressRequest{PaymentID: "p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.NewAddress(chains.AddressRequest{PaymentID: "p2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Address != depositAddress || second.Address != depositAddress || first.PrivateKey != "" {
+		t.Errorf("NewAddress() = %+v, want the merchant address", first)
+	}
+	if first.Reference == second.Reference {
+		t.Error("payments share a reference")
+	}
+	if _, err := DecodePublicKey(first.Reference); err != nil {
+		t.Errorf("reference %s is not a public key: %v", first.Reference, err)
+	}
+}
@@ -0,0 +1,96 @@
+package solana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"net/url"
+	"strings"
+)
+
+// PaymentURI returns the Solana Pay transfer request for req:
+//
+//	solana:<recipient>?amount=<amount>&spl-token=<mint>&reference=<key>&label=<label>&memo=<memo>
+//
+// The amount is rounded up to the token's decimals, so a wallet paying it
+// exactly never falls short of the expected amount.
+func (a *Adapter) PaymentURI(req chains.PaymentRequest) (string, error) {
+	if _, err := DecodePublicKey(req.Address); err != nil {
+		return "", fmt.Errorf("invalid Solana address %q: %w", req.Address, err)
+	}
+
+	query := url.Values{}
+	query.Set("amount", req.Amount.RoundUp(int32(req.Token.Decimals)).String())
+	if req.Token.Token != models.TokenNative {
+		if req.Token.Contract == "" {
+			return "", fmt.Errorf("no mint configured for %s", req.Token.Symbol)
+		}
+		query.Set("spl-token", req.Token.Contract)
+	}
+	if req.Reference != "" {
+		query.Set("reference", req.Reference)
+	}
+	if req.Label != "" {
+		query.Set("label", req.Label)
+	}
+	if req.Memo != "" {
+		query.Set("memo", req.Memo)
+	}
+	// Wallets decode the query like encodeURIComponent, not as a form
+	return "solana:" + req.Address + "?" + strings.ReplaceAll(query.Encode(), "+", "%20"), nil
+}
+
+// PaymentTransaction builds the transfer of req from payer for a Solana Pay
+// transaction request. The payer signs it and pays the fees, as well as
+// the rent of the recipient's token account if it has none yet, which is
+// the case for fresh deposit addresses. As in a transfer request, the
+// memo instruction comes right before the transfer, and the reference is
+// a read-only account of the transfer instruction.
+func (a *Adapter) PaymentTransaction(ctx context.Context, req chains.PaymentRequest, payer string) ([]byte, error) {
+	if _, err := DecodePublicKey(payer); err != nil {
+		return nil, fmt.Errorf("%w: %v", chains.ErrInvalidPayer, err)
+	}
+	amount := req.Amount.Shift(int32(req.Token.Decimals)).Ceil().BigInt()
+	if !amount.IsUint64() {
+		return nil, errors.New("payment amount out of range")
+	}
+
+	var instructions []instruction
+	var transfer instruction
+	if req.Token.Token == models.TokenNative {
+		transfer = systemTransferInstruction(payer, req.Address, amount.Uint64())
+	} else {
+		if req.Token.Contract == "" {
+			return nil, fmt.Errorf("no mint configured for %s", req.Token.Symbol)
+		}
+		source, err := AssociatedTokenAddress(payer, req.Token.Contract)
+		if err != nil {
+			return nil, err
+		}
+		destination, err := AssociatedTokenAddress(req.Address, req.Token.Contract)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, createTokenAccountInstruction(payer, destination, req.Address, req.Token.Contract))
+		transfer = transferCheckedInstruction(source, destination, payer, req.Token, amount.Uint64())
+	}
+
+	if req.Memo != "" {
+		instructions = append(instructions, instruction{
+			programID: MemoProgramID,
+			data:      []byte(req.Memo),
+		})
+	}
+	if req.Reference != "" {
+		transfer.accounts = append(transfer.accounts, accountMeta{pubkey: req.Reference})
+	}
+	instructions = append(instructions, transfer)
+
+	blockhash, err := a.rpc.getLatestBlockhash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return unsignedTransaction(instructions, payer, blockhash)
+}
@@ -0,0 +1,80 @@
+package solana
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// PublicKeySize is the length of a Solana account address in bytes.
+const PublicKeySize = 32
+
+// Well-known program addresses
+const (
+	SystemProgramID                 = "11111111111111111111111111111111"
+	TokenProgramID                  = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	AssociatedTokenAccountProgramID = "ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL"
+	MemoProgramID                   = "MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr"
+)
+
+// DecodePublicKey decodes a base58 Solana address.
+func DecodePublicKey(address string) ([]byte, error) {
+	key := base58.Decode(address)
+	if len(key) != PublicKeySize {
+		return nil, fmt.Errorf("expected %d bytes, got %d", PublicKeySize, len(key))
+	}
+	return key, nil
+}
+
+// isOnCurve reports whether key is a valid ed25519 point, i.e. an address
+// that could have a private key.
+func isOnCurve(key []byte) bool {
+	_, err := new(edwards25519.Point).SetBytes(key)
+	return err == nil
+}
+
+// FindProgramAddress derives the program-derived address for seeds, trying
+// bump seeds from 255 down until the result is off the ed25519 curve.
+func FindProgramAddress(seeds [][]byte, programID string) (string, byte, error) {
+	program, err := DecodePublicKey(programID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for bump := 255; bump >= 0; bump-- {
+		h := sha256.New()
+		for _, seed := range seeds {
+			h.Write(seed)
+		}
+		h.Write([]byte{byte(bump)})
+		h.Write(program)
+		h.Write([]byte("ProgramDerivedAddress"))
+		address := h.Sum(nil)
+
+		if !isOnCurve(address) {
+			return base58.Encode(address), byte(bump), nil
+		}
+	}
+
+	return "", 0, errors.New("no viable bump seed found")
+}
+
+// AssociatedTokenAddress returns the associated token account holding mint
+// for owner.
+func AssociatedTokenAddress(owner, mint string) (string, error) {
+	ownerKey, err := DecodePublicKey(owner)
+	if err != nil {
+		return "", fmt.Errorf("invalid owner: %w", err)
+	}
+	mintKey, err := DecodePublicKey(mint)
+	if err != nil {
+		return "", fmt.Errorf("invalid mint: %w", err)
+	}
+	tokenProgram, _ := DecodePublicKey(TokenProgramID)
+
+	address, _, err := FindProgramAddress([][]byte{ownerKey, tokenProgram, mintKey}, AssociatedTokenAccountProgramID)
+	return address, err
+}
@@ -0,0 +1,244 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// rpcClient is a minimal Solana JSON-RPC 2.0 client.
+type rpcClient struct {
+	url    string
+	http   *http.Client
+	nextID atomic.Uint64
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("solana rpc error %d: %s", e.Code, e.Message)
+}
+
+func newRPCClient(url string) *rpcClient {
+	return &rpcClient{
+		url:  url,
+		http: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("solana rpc returned status %d", resp.StatusCode)
+	}
+
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+type signatureInfo struct {
+	Signature string          `json:"signature"`
+	Slot      uint64          `json:"slot"`
+	Err       json.RawMessage `json:"err"`
+}
+
+func (c *rpcClient) getSignaturesForAddress(ctx context.Context, address string, limit int) ([]signatureInfo, error) {
+	var result []signatureInfo
+	err := c.call(ctx, "getSignaturesForAddress", &result, address, map[string]interface{}{
+		"limit":      limit,
+		"commitment": "confirmed",
+	})
+	return result, err
+}
+
+type transaction struct {
+	Slot uint64 `json:"slot"`
+	Meta *struct {
+		Err               json.RawMessage `json:"err"`
+		PreBalances       []uint64        `json:"preBalances"`
+		PostBalances      []uint64        `json:"postBalances"`
+		PreTokenBalances  []tokenBalance  `json:"preTokenBalances"`
+		PostTokenBalances []tokenBalance  `json:"postTokenBalances"`
+		LoadedAddresses   *struct {
+			Writable []string `json:"writable"`
+			Readonly []string `json:"readonly"`
+		} `json:"loadedAddresses"`
+	} `json:"meta"`
+	Transaction struct {
+		Signatures []string `json:"signatures"`
+		Message    struct {
+			AccountKeys []string `json:"accountKeys"`
+		} `json:"message"`
+	} `json:"transaction"`
+}
+
+type tokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UITokenAmount struct {
+		Amount   string `json:"amount"`
+		Decimals int    `json:"decimals"`
+	} `json:"uiTokenAmount"`
+}
+
+// accountKeys returns the transaction's static and address-table accounts in
+// the order balance arrays are indexed by.
+func (t *transaction) accountKeys() []string {
+	keys := append([]string{}, t.Transaction.Message.AccountKeys...)
+	if t.Meta != nil && t.Meta.LoadedAddresses != nil {
+		keys = append(keys, t.Meta.LoadedAddresses.Writable...)
+		keys = append(keys, t.Meta.LoadedAddresses.Readonly...)
+	}
+	return keys
+}
+
+func (c *rpcClient) getTransaction(ctx context.Context, signature string) (*transaction, error) {
+	var result *transaction
+	err := c.call(ctx, "getTransaction", &result, signature, map[string]interface{}{
+		"encoding":                       "json",
+		"commitment":                     "confirmed",
+		"maxSupportedTransactionVersion": 0,
+	})
+	return result, err
+}
+
+type signatureStatus struct {
+	Slot               uint64          `json:"slot"`
+	Confirmations      *int            `json:"confirmations"`
+	Err                json.RawMessage `json:"err"`
+	ConfirmationStatus string          `json:"confirmationStatus"`
+}
+
+func (c *rpcClient) getSignatureStatus(ctx context.Context, signature string) (*signatureStatus, error) {
+	var result struct {
+		Value []*signatureStatus `json:"value"`
+	}
+	err := c.call(ctx, "getSignatureStatuses", &result, []string{signature}, map[string]interface{}{
+		"searchTransactionHistory": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Value) == 0 {
+		return nil, nil
+	}
+	return result.Value[0], nil
+}
+
+// failed reports whether a JSON-RPC "err" field holds an error.
+func failed(err json.RawMessage) bool {
+	return len(err) > 0 && string(err) != "null"
+}
+
+func (c *rpcClient) getBalance(ctx context.Context, address string) (uint64, error) {
+	var result struct {
+		Value uint64 `json:"value"`
+	}
+	err := c.call(ctx, "getBalance", &result, address, map[string]interface{}{
+		"commitment": "confirmed",
+	})
+	return result.Value, err
+}
+
+// getTokenAccountBalance returns the raw amount held by a token account, or
+// nil if the account does not exist.
+func (c *rpcClient) getTokenAccountBalance(ctx context.Context, account string) (*big.Int, error) {
+	var info struct {
+		Value *struct {
+			Data struct {
+				Parsed struct {
+					Info struct {
+						TokenAmount struct {
+							Amount string `json:"amount"`
+						} `json:"tokenAmount"`
+					} `json:"info"`
+				} `json:"parsed"`
+			} `json:"data"`
+		} `json:"value"`
+	}
+	err := c.call(ctx, "getAccountInfo", &info, account, map[string]interface{}{
+		"encoding":   "jsonParsed",
+		"commitment": "confirmed",
+	})
+	if err != nil || info.Value == nil {
+		return nil, err
+	}
+
+	amount, ok := new(big.Int).SetString(info.Value.Data.Parsed.Info.TokenAmount.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("unexpected token account data for %s", account)
+	}
+	return amount, nil
+}
+
+func (c *rpcClient) getLatestBlockhash(ctx context.Context) (string, error) {
+	var result struct {
+		Value struct {
+			Blockhash string `json:"blockhash"`
+		} `json:"value"`
+	}
+	err := c.call(ctx, "getLatestBlockhash", &result, map[string]interface{}{
+		"commitment": "confirmed",
+	})
+	return result.Value.Blockhash, err
+}
+
+// sendTransaction submits a signed wire-encoded transaction and returns its
+// signature.
+func (c *rpcClient) sendTransaction(ctx context.Context, tx []byte) (string, error) {
+	var signature string
+	err := c.call(ctx, "sendTransaction", &signature, base64.StdEncoding.EncodeToString(tx), map[string]interface{}{
+		"encoding":            "base64",
+		"preflightCommitment": "confirmed",
+	})
+	return signature, err
+}
@@ -0,0 +1,216 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// lamportsPerSignature is the base fee per transaction signature.
+const lamportsPerSignature = 5000
+
+// Instruction indexes of the programs used for transfers
+const (
+	systemTransfer                  = 2
+	tokenTransferChecked            = 12
+	tokenCloseAccount               = 9
+	associatedTokenCreateIdempotent = 1
+)
+
+func encodePublicKey(key ed25519.PrivateKey) string {
+	return base58.Encode(key.Public().(ed25519.PublicKey))
+}
+
+func decodeHexOrBase58(s string) ([]byte, error) {
+	if raw, err := hex.DecodeString(s); err == nil {
+		return raw, nil
+	}
+	raw := base58.Decode(s)
+	if len(raw) == 0 {
+		return nil, errors.New("neither hex nor base58")
+	}
+	return raw, nil
+}
+
+func (a *Adapter) AddressOf(privateKey string) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return encodePublicKey(key), nil
+}
+
+// Balance returns the lamports of address, or the balance of its
+// associated token account for SPL tokens.
+func (a *Adapter) Balance(ctx context.Context, address string, token chains.Token) (*big.Int, error) {
+	if token.Token == models.TokenNative {
+		lamports, err := a.rpc.getBalance(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetUint64(lamports), nil
+	}
+
+	tokenAccount, err := AssociatedTokenAddress(address, token.Contract)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := a.rpc.getTokenAccountBalance(ctx, tokenAccount)
+	if err != nil || amount == nil {
+		return new(big.Int), err
+	}
+	return amount, nil
+}
+
+// TransferFee is the signature fee of a transfer the sender pays for
+// itself. Token transfers additionally need the recipient's token account
+// to exist or be created, so they should be sponsored instead.
+func (a *Adapter) TransferFee(ctx context.Context, token chains.Token) (*big.Int, error) {
+	return big.NewInt(lamportsPerSignature), nil
+}
+
+// SponsorsFees is always true: any account can pay a transaction's fees by
+// signing it as the fee payer. A sponsored SPL transfer also creates the
+// recipient's token account if needed, and closes the emptied source
+// account, returning its rent to the fee payer.
+func (a *Adapter) SponsorsFees() bool {
+	return true
+}
+
+// Transfer sends SOL, or sweeps an SPL token: token transfers must move the
+// whole balance of the source token account, which is closed afterwards.
+func (a *Adapter) Transfer(ctx context.Context, req chains.TransferRequest) (string, error) {
+	key, err := parsePrivateKey(req.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	if !req.Amount.IsUint64() {
+		return "", errors.New("transfer amount out of range")
+	}
+	owner := encodePublicKey(key)
+
+	signers := []ed25519.PrivateKey{key}
+	feePayer := owner
+	if req.FeePayerKey != "" {
+		payerKey, err := parsePrivateKey(req.FeePayerKey)
+		if err != nil {
+			return "", err
+		}
+		signers = append(signers, payerKey)
+		feePayer = encodePublicKey(payerKey)
+	}
+
+	var instructions []instruction
+	if req.Token.Token == models.TokenNative {
+		instructions = []instruction{systemTransferInstruction(owner, req.To, req.Amount.Uint64())}
+	} else {
+		instructions, err = tokenSweepInstructions(owner, req.To, feePayer, req.Token, req.Amount.Uint64())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	blockhash, err := a.rpc.getLatestBlockhash(ctx)
+	if err != nil {
+		return "", err
+	}
+	tx, err := buildTransaction(instructions, feePayer, blockhash, signers)
+	if err != nil {
+		return "", err
+	}
+	return a.rpc.sendTransaction(ctx, tx)
+}
+
+func systemTransferInstruction(from, to string, lamports uint64) instruction {
+	data := binary.LittleEndian.AppendUint32(nil, systemTransfer)
+	return instruction{
+		programID: SystemProgramID,
+		accounts: []accountMeta{
+			{pubkey: from, signer: true, writable: true},
+			{pubkey: to, writable: true},
+		},
+		data: binary.LittleEndian.AppendUint64(data, lamports),
+	}
+}
+
+// tokenSweepInstructions moves amount of token, which must be the whole
+// balance, from owner's associated token account to recipient's, creating
+// the latter if needed. The emptied source account is closed; feePayer
+// pays for the new account and receives the closed one's rent.
+func tokenSweepInstructions(owner, recipient, feePayer string, token chains.Token, amount uint64) ([]instruction, error) {
+	source, err := AssociatedTokenAddress(owner, token.Contract)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := AssociatedTokenAddress(recipient, token.Contract)
+	if err != nil {
+		return nil, err
+	}
+
+	return []instruction{
+		createTokenAccountInstruction(feePayer, destination, recipient, token.Contract),
+		transferCheckedInstruction(source, destination, owner, token, amount),
+		{
+			programID: TokenProgramID,
+			accounts: []accountMeta{
+				{pubkey: source, writable: true},
+				{pubkey: feePayer, writable: true},
+				{pubkey: owner, signer: true},
+			},
+			data: []byte{tokenCloseAccount},
+		},
+	}, nil
+}
+
+// createTokenAccountInstruction creates account, owner's associated token
+// account for mint, paid for by feePayer, unless it already exists.
+func createTokenAccountInstruction(feePayer, account, owner, mint string) instruction {
+	return instruction{
+		programID: AssociatedTokenAccountProgramID,
+		accounts: []accountMeta{
+			{pubkey: feePayer, signer: true, writable: true},
+			{pubkey: account, writable: true},
+			{pubkey: owner},
+			{pubkey: mint},
+			{pubkey: SystemProgramID},
+			{pubkey: TokenProgramID},
+		},
+		data: []byte{associatedTokenCreateIdempotent},
+	}
+}
+
+// transferCheckedInstruction moves amount of token between token accounts.
+func transferCheckedInstruction(source, destination, owner string, token chains.Token, amount uint64) instruction {
+	data := append([]byte{tokenTransferChecked}, binary.LittleEndian.AppendUint64(nil, amount)...)
+	return instruction{
+		programID: TokenProgramID,
+		accounts: []accountMeta{
+			{pubkey: source, writable: true},
+			{pubkey: token.Contract},
+			{pubkey: destination, writable: true},
+			{pubkey: owner, signer: true},
+		},
+		data: append(data, byte(token.Decimals)),
+	}
+}
+
+func (a *Adapter) TransferStatus(ctx context.Context, txHash string) (chains.TransferStatus, error) {
+	status, err := a.rpc.getSignatureStatus(ctx, txHash)
+	if err != nil || status == nil {
+		return chains.TransferPending, err
+	}
+	if failed(status.Err) {
+		return chains.TransferFailed, nil
+	}
+	if status.ConfirmationStatus == "confirmed" || status.ConfirmationStatus == "finalized" {
+		return chains.TransferSucceeded, nil
+	}
+	return chains.TransferPending, nil
+}
@@ -0,0 +1,170 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// accountMeta is an account an instruction reads or writes.
+type accountMeta struct {
+	pubkey   string
+	signer   bool
+	writable bool
+}
+
+// instruction is a call of an on-chain program.
+type instruction struct {
+	programID string
+	accounts  []accountMeta
+	data      []byte
+}
+
+// buildTransaction compiles instructions into a legacy transaction paid
+// for by feePayer and signs it with signers, which must include the fee
+// payer. It returns the wire encoding.
+func buildTransaction(instructions []instruction, feePayer string, blockhash string, signers []ed25519.PrivateKey) ([]byte, error) {
+	message, accounts, err := compileMessage(instructions, feePayer, blockhash)
+	if err != nil {
+		return nil, err
+	}
+
+	// One signature per signer account, in account order
+	signatures := make([][]byte, len(accounts))
+	for _, signer := range signers {
+		address := encodePublicKey(signer)
+		for i, account := range accounts {
+			if account == address {
+				signatures[i] = ed25519.Sign(signer, message)
+			}
+		}
+	}
+
+	tx := appendCompactU16(nil, len(signatures))
+	for i, signature := range signatures {
+		if signature == nil {
+			return nil, fmt.Errorf("missing signature of %s", accounts[i])
+		}
+		tx = append(tx, signature...)
+	}
+	return append(tx, message...), nil
+}
+
+// unsignedTransaction compiles instructions like buildTransaction, but
+// leaves every signature zeroed for the signers to fill in, as wallets do
+// with transactions they are handed.
+func unsignedTransaction(instructions []instruction, feePayer string, blockhash string) ([]byte, error) {
+	message, accounts, err := compileMessage(instructions, feePayer, blockhash)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := appendCompactU16(nil, len(accounts))
+	tx = append(tx, make([]byte, len(accounts)*ed25519.SignatureSize)...)
+	return append(tx, message...), nil
+}
+
+// compileMessage encodes instructions as a legacy transaction message and
+// returns it with the accounts that have to sign it, in signature order.
+func compileMessage(instructions []instruction, feePayer string, blockhash string) ([]byte, []string, error) {
+	// Collect the accounts, merging the flags of repeated ones. The fee
+	// payer comes first, followed by writable signers, read-only signers,
+	// writable non-signers and read-only non-signers.
+	metas := []accountMeta{{pubkey: feePayer, signer: true, writable: true}}
+	index := map[string]int{feePayer: 0}
+	add := func(meta accountMeta) {
+		if i, ok := index[meta.pubkey]; ok {
+			metas[i].signer = metas[i].signer || meta.signer
+			metas[i].writable = metas[i].writable || meta.writable
+			return
+		}
+		index[meta.pubkey] = len(metas)
+		metas = append(metas, meta)
+	}
+	for _, ix := range instructions {
+		for _, meta := range ix.accounts {
+			add(meta)
+		}
+		add(accountMeta{pubkey: ix.programID})
+	}
+
+	var ordered []accountMeta
+	for _, class := range []struct{ signer, writable bool }{{true, true}, {true, false}, {false, true}, {false, false}} {
+		for _, meta := range metas {
+			if meta.signer == class.signer && meta.writable == class.writable {
+				ordered = append(ordered, meta)
+			}
+		}
+	}
+
+	var numSigners, readonlySigned, readonlyUnsigned byte
+	position := make(map[string]int, len(ordered))
+	keys := make([][]byte, len(ordered))
+	for i, meta := range ordered {
+		key, err := DecodePublicKey(meta.pubkey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid account %s: %w", meta.pubkey, err)
+		}
+		keys[i] = key
+		position[meta.pubkey] = i
+
+		switch {
+		case meta.signer && !meta.writable:
+			numSigners++
+			readonlySigned++
+		case meta.signer:
+			numSigners++
+		case !meta.writable:
+			readonlyUnsigned++
+		}
+	}
+
+	recentBlockhash, err := DecodePublicKey(blockhash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid blockhash: %w", err)
+	}
+
+	message := []byte{numSigners, readonlySigned, readonlyUnsigned}
+	message = appendCompactU16(message, len(keys))
+	for _, key := range keys {
+		message = append(message, key...)
+	}
+	message = append(message, recentBlockhash...)
+	message = appendCompactU16(message, len(instructions))
+	for _, ix := range instructions {
+		message = append(message, byte(position[ix.programID]))
+		message = appendCompactU16(message, len(ix.accounts))
+		for _, meta := range ix.accounts {
+			message = append(message, byte(position[meta.pubkey]))
+		}
+		message = appendCompactU16(message, len(ix.data))
+		message = append(message, ix.data...)
+	}
+
+	accounts := make([]string, numSigners)
+	for i := range accounts {
+		accounts[i] = ordered[i].pubkey
+	}
+	return message, accounts, nil
+}
+
+// appendCompactU16 appends n in Solana's "shortvec" encoding.
+func appendCompactU16(b []byte, n int) []byte {
+	for {
+		if n < 0x80 {
+			return append(b, byte(n))
+		}
+		b = append(b, byte(n&0x7f|0x80))
+		n >>= 7
+	}
+}
+
+// parsePrivateKey accepts an ed25519 private key as 64 bytes in hex, the
+// form NewAddress returns, or in base58, the form wallets export.
+func parsePrivateKey(privateKey string) (ed25519.PrivateKey, error) {
+	key, err := decodeHexOrBase58(privateKey)
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Solana private key: expected 64 bytes in hex or base58")
+	}
+	return ed25519.PrivateKey(key), nil
+}
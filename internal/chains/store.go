@@ -0,0 +1,33 @@
+package chains
+
+import "multi-chain-payment-gateway/internal/models"
+
+// Store persists the state adapters need to survive restarts.
+type Store interface {
+	// NextIndex reserves the next unused HD derivation index in scope.
+	// Indexes are never handed out twice.
+	NextIndex(scope string) (uint32, error)
+
+	// LoadCursor returns the last block a chain scanner processed, or nil
+	// if the chain has never been scanned.
+	LoadCursor(chain models.Chain) (*Cursor, error)
+
+	// SaveCursor records scanner progress for chain.
+	SaveCursor(chain models.Chain, cursor Cursor) error
+
+	// ReserveNonce reserves the next transaction nonce of address on chain,
+	// at least minimum. Nonces are never reserved twice.
+	ReserveNonce(chain models.Chain, address string, minimum uint64) (uint64, error)
+
+	// ReleaseNonce gives back a reserved nonce whose transaction was never
+	// broadcast, if no later nonce has been reserved since.
+	ReleaseNonce(chain models.Chain, address string, nonce uint64) error
+}
+
+// Cursor marks the last block a scanner has processed. Chains scanned per
+// account, like TON, store the logical time and hash of the account's last
+// processed transaction instead.
+type Cursor struct {
+	Height uint64
+	Hash   string
+}
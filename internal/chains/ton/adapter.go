@@ -0,0 +1,387 @@
+package ton
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// transactionLimit bounds how many recent transactions are inspected per
+// deposit address on each check. A shared merchant address sees every
+// payment, so it is paged through instead; see scanMerchant.
+const transactionLimit = 25
+
+// Op codes a jetton wallet uses to notify its owner of an incoming jetton
+// transfer and to return unspent TON (TEP-74).
+const (
+	opTransferNotification = 0x7362d09c
+	opExcesses             = 0xd53276db
+	opComment              = 0
+)
+
+// Config configures the TON adapter.
+type Config struct {
+	RPCURL        string
+	APIKey        string
+	Tokens        []chains.Token
+	Confirmations int
+
+	// MerchantAddress, if set, receives every payment, told apart by the
+	// payment ID as the transfer comment; no deposit keys are generated.
+	MerchantAddress string
+}
+
+// Adapter accepts TON and jetton stablecoin payments on TON.
+type Adapter struct {
+	config Config
+	store  chains.Store
+	rpc    *rpcClient
+
+	// position is the newest merchant address transaction seen, which may
+	// be ahead of the persisted cursor.
+	position *chains.Cursor
+
+	// jettonWallets caches the jetton wallet address of each deposit
+	// address, keyed by owner and jetton master.
+	mu            sync.Mutex
+	jettonWallets map[[2]string]string
+
+	// referenced holds incoming merchant address transactions until they
+	// are matched, keyed by the reference in their comment.
+	referenced map[string][]*transaction
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	if cfg.MerchantAddress != "" {
+		if _, err := ParseAddress(cfg.MerchantAddress); err != nil {
+			return nil, fmt.Errorf("invalid TON_MERCHANT_ADDRESS: %w", err)
+		}
+	}
+
+	return &Adapter{
+		config:        cfg,
+		store:         store,
+		rpc:           newRPCClient(cfg.RPCURL, cfg.APIKey),
+		jettonWallets: make(map[[2]string]string),
+		referenced:    make(map[string][]*transaction),
+	}, nil
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return models.ChainTON
+}
+
+// NewAddress creates a wallet v4R2 deposit address. It is returned in the
+// non-bounceable form, since the wallet contract is only deployed when the
+// funds are swept. With a merchant address, that address is returned
+// instead, with the payment ID as the comment to send along.
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	if a.config.MerchantAddress != "" {
+		return &chains.Wallet{
+			Address:   a.config.MerchantAddress,
+			Chain:     models.ChainTON,
+			Reference: req.PaymentID,
+		}, nil
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := WalletAddress(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:    FriendlyAddress(addr, false),
+		PrivateKey: hex.EncodeToString(privateKey.Seed()),
+		Chain:      models.ChainTON,
+	}, nil
+}
+
+// DerivesPublicly reports whether payments go to the merchant address,
+// which needs no keys.
+func (a *Adapter) DerivesPublicly() bool {
+	return a.config.MerchantAddress != ""
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	if _, err := ParseAddress(address); err != nil {
+		return fmt.Errorf("invalid TON address %q: %w", address, err)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+// Sync drops cached jetton wallets of addresses that are no longer awaited
+// and, with a merchant address, collects its new transactions.
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	watched := make(map[string]bool, len(deposits))
+	references := make(map[string]bool)
+	for _, deposit := range deposits {
+		watched[deposit.Address] = true
+		if deposit.Reference != "" {
+			references[deposit.Reference] = true
+		}
+	}
+
+	a.mu.Lock()
+	for key := range a.jettonWallets {
+		if !watched[key[0]] {
+			delete(a.jettonWallets, key)
+		}
+	}
+	a.mu.Unlock()
+
+	if a.config.MerchantAddress == "" {
+		return nil
+	}
+	return a.scanMerchant(ctx, references)
+}
+
+// CheckPayment looks through the deposit address's recent transactions for
+// an incoming transfer of at least the expected amount. TON arrives as the
+// value of an internal message; jettons as a transfer_notification sent by
+// the deposit address's own jetton wallet. Deposits with a reference must
+// carry it as the transfer's text comment, and are looked up among the
+// merchant address transactions collected by Sync.
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	var jettonWallet string
+	if deposit.Token.Token != models.TokenNative {
+		if deposit.Token.Contract == "" {
+			return nil, nil
+		}
+
+		var err error
+		jettonWallet, err = a.jettonWallet(ctx, deposit.Address, deposit.Token.Contract)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if deposit.Reference != "" {
+		return a.takeReferenced(deposit, jettonWallet), nil
+	}
+
+	txs, err := a.rpc.getTransactions(ctx, deposit.Address, transactionLimit, "", "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range txs {
+		if transfer := matchTransfer(&txs[i], deposit, jettonWallet); transfer != nil {
+			return transfer, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchTransfer returns the transfer tx makes to deposit, or nil if tx does
+// not pay it in full.
+func matchTransfer(tx *transaction, deposit chains.Deposit, jettonWallet string) *models.Transaction {
+	if tx.InMsg == nil || tx.InMsg.Source == "" || bounced(tx) {
+		return nil
+	}
+
+	var transfer *models.Transaction
+	if deposit.Token.Token == models.TokenNative {
+		transfer = nativeTransfer(tx, deposit.Reference)
+	} else {
+		transfer = jettonTransfer(tx, jettonWallet, deposit.Reference)
+	}
+	if transfer == nil || transfer.Amount.LessThan(deposit.Amount.Shift(int32(deposit.Token.Decimals))) {
+		return nil
+	}
+
+	transfer.Chain = models.ChainTON
+	transfer.TxHash = tx.TransactionID.Hash
+	transfer.ToAddress = deposit.Address
+	transfer.Token = deposit.Token.Token
+	transfer.Amount = transfer.Amount.Shift(-int32(deposit.Token.Decimals))
+	if lt, ok := logicalTime(tx); ok {
+		transfer.BlockNumber = lt
+	}
+	if deposit.Token.Token != models.TokenNative {
+		transfer.TokenContract = deposit.Token.Contract
+		transfer.TokenAccount = jettonWallet
+	}
+	return transfer
+}
+
+// logicalTime returns the logical time of tx, which orders the
+// transactions of an account.
+func logicalTime(tx *transaction) (uint64, bool) {
+	lt, ok := new(big.Int).SetString(tx.TransactionID.LT, 10)
+	if !ok || !lt.IsUint64() {
+		return 0, false
+	}
+	return lt.Uint64(), true
+}
+
+// bounced reports whether tx sent its incoming value straight back, as
+// happens when a bounceable message reaches an undeployed wallet.
+func bounced(tx *transaction) bool {
+	for _, out := range tx.OutMsgs {
+		if SameAddress(out.Destination, tx.InMsg.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// nativeTransfer returns the TON carried by tx's incoming message, in
+// nanotons. Jetton messages carry some TON too, but pay the jetton option
+// of a deposit address rather than its TON option. A non-empty reference
+// has to match the message's comment.
+func nativeTransfer(tx *transaction, reference string) *models.Transaction {
+	value, err := decimal.NewFromString(tx.InMsg.Value)
+	if err != nil || !value.IsPositive() {
+		return nil
+	}
+	if body := tx.InMsg.body(); body != nil {
+		if op, err := body.LoadUInt(32); err == nil && (op == opTransferNotification || op == opExcesses) {
+			return nil
+		}
+	}
+	if reference != "" && tx.InMsg.comment() != reference {
+		return nil
+	}
+
+	return &models.Transaction{
+		FromAddress: tx.InMsg.Source,
+		Amount:      value,
+	}
+}
+
+// jettonTransfer parses a transfer_notification from jettonWallet and
+// returns the jettons received, in base units, and their original sender.
+// A non-empty reference has to match the comment in the forward payload.
+//
+//	transfer_notification#7362d09c query_id:uint64 amount:(VarUInteger 16)
+//	    sender:MsgAddress forward_payload:(Either Cell ^Cell) = InternalMsgBody;
+func jettonTransfer(tx *transaction, jettonWallet, reference string) *models.Transaction {
+	// Anyone can send a notification; only our jetton wallet's is genuine
+	if !SameAddress(tx.InMsg.Source, jettonWallet) {
+		return nil
+	}
+
+	body := tx.InMsg.body()
+	if body == nil {
+		return nil
+	}
+	if op, err := body.LoadUInt(32); err != nil || op != opTransferNotification {
+		return nil
+	}
+	if _, err := body.LoadUInt(64); err != nil {
+		return nil
+	}
+	amount, err := body.LoadBigCoins()
+	if err != nil || amount.Sign() <= 0 {
+		return nil
+	}
+	sender, err := body.LoadAddr()
+	if err != nil {
+		return nil
+	}
+	if reference != "" && forwardComment(body) != reference {
+		return nil
+	}
+
+	from := tx.InMsg.Source
+	if !sender.IsAddrNone() {
+		from = sender.String()
+	}
+
+	return &models.Transaction{
+		FromAddress: from,
+		Amount:      decimal.NewFromBigInt(amount, 0),
+	}
+}
+
+// forwardComment returns the text comment of a forward_payload, which is
+// either inline or in a referenced cell.
+func forwardComment(body *cell.Slice) string {
+	inRef, err := body.LoadBoolBit()
+	if err != nil {
+		return ""
+	}
+	payload := body
+	if inRef {
+		if payload, err = body.LoadRef(); err != nil {
+			return ""
+		}
+	}
+	return textComment(payload)
+}
+
+// textComment returns the text of a comment body, a zero op code followed
+// by the text as a snake string, or "" if body is something else.
+func textComment(body *cell.Slice) string {
+	if op, err := body.LoadUInt(32); err != nil || op != opComment {
+		return ""
+	}
+	text, err := body.LoadStringSnake()
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// jettonWallet returns the address of owner's jetton wallet for master.
+// It is deterministic, so it is looked up once per deposit address.
+func (a *Adapter) jettonWallet(ctx context.Context, owner, master string) (string, error) {
+	key := [2]string{owner, master}
+
+	a.mu.Lock()
+	wallet, ok := a.jettonWallets[key]
+	a.mu.Unlock()
+	if ok {
+		return wallet, nil
+	}
+
+	ownerAddr, err := ParseAddress(owner)
+	if err != nil {
+		return "", err
+	}
+	addr, err := a.rpc.getJettonWallet(ctx, master, ownerAddr)
+	if err != nil {
+		return "", err
+	}
+	wallet = FriendlyAddress(addr, true)
+
+	a.mu.Lock()
+	a.jettonWallets[key] = wallet
+	a.mu.Unlock()
+	return wallet, nil
+}
+
+// Confirmations reports the required depth once the transaction is served
+// by toncenter: masterchain blocks are final as soon as they are committed,
+// so there is nothing deeper to wait for.
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	found, err := a.rpc.getTransaction(ctx, tx.ToAddress, fmt.Sprint(tx.BlockNumber), tx.TxHash)
+	if err != nil {
+		return 0, err
+	}
+	if found == nil {
+		return 0, nil
+	}
+	return a.config.Confirmations, nil
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return a.config.Confirmations
+}
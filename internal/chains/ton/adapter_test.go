@@ -0,0 +1,378 @@
+package ton
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var (
+	depositAddress = testAddress(1, false)
+	merchant       = testAddress(2, true)
+	jettonWallet   = testAddress(3, true)
+	jettonMaster   = testAddress(4, true)
+	payer          = testAddress(5, true)
+	impostor       = testAddress(6, true)
+
+	ton  = chains.Token{Token: models.TokenNative, Symbol: "TON", Decimals: 9}
+	usdt = chains.Token{Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6, Contract: jettonMaster}
+)
+
+func testAddress(fill byte, bounceable bool) string {
+	data := make([]byte, 32)
+	for i := range data {
+		data[i] = fill
+	}
+	return FriendlyAddress(address.NewAddress(0, 0, data), bounceable)
+}
+
+// fakeToncenter serves account histories, newest first, and the jetton
+// wallet of every owner.
+type fakeToncenter struct {
+	t       *testing.T
+	mu      sync.Mutex
+	history map[string][]transaction
+	calls   int
+}
+
+func newFakeToncenter(t *testing.T) (*fakeToncenter, string) {
+	t.Helper()
+	f := &fakeToncenter{t: t, history: make(map[string][]transaction)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server.URL
+}
+
+func (f *fakeToncenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "getTransactions":
+		var params struct {
+			Address string `json:"address"`
+			Limit   int    `json:"limit"`
+			LT      string `json:"lt"`
+			Hash    string `json:"hash"`
+		}
+		json.Unmarshal(req.Params, &params)
+		result = f.transactions(params.Address, params.Limit, params.LT, params.Hash)
+	case "runGetMethod":
+		wallet, _ := ParseAddress(jettonWallet)
+		boc := cell.BeginCell().MustStoreAddr(wallet).EndCell().ToBOC()
+		result = map[string]interface{}{
+			"exit_code": 0,
+			"stack": []interface{}{
+				[]interface{}{"cell", map[string]string{"bytes": base64.StdEncoding.EncodeToString(boc)}},
+			},
+		}
+	default:
+		f.t.Errorf("unexpected toncenter call %s", req.Method)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+func (f *fakeToncenter) transactions(account string, limit int, lt, hash string) []transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	history := f.history[account]
+	start := 0
+	if lt != "" {
+		start = len(history)
+		for i, tx := range history {
+			if tx.TransactionID.LT == lt && tx.TransactionID.Hash == hash {
+				start = i
+				break
+			}
+		}
+	}
+	end := start + limit
+	if end > len(history) {
+		end = len(history)
+	}
+	return append([]transaction{}, history[start:end]...)
+}
+
+// receive adds tx as the newest transaction of account.
+func (f *fakeToncenter) receive(account string, tx transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.history[account] = append([]transaction{tx}, f.history[account]...)
+}
+
+func (f *fakeToncenter) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func transactionAt(lt uint64, in message) transaction {
+	return transaction{
+		TransactionID: transactionID{LT: fmt.Sprint(lt), Hash: fmt.Sprintf("hash-%d", lt)},
+		InMsg:         &in,
+	}
+}
+
+// tonPayment is a TON transfer of nanotons with an optional comment.
+func tonPayment(lt uint64, nanotons, comment string) transaction {
+	in := message{Source: payer, Value: nanotons}
+	if comment != "" {
+		in.MsgData.Type = "msg.dataText"
+		in.MsgData.Text = base64.StdEncoding.EncodeToString([]byte(comment))
+	}
+	return transactionAt(lt, in)
+}
+
+// jettonPayment is a transfer_notification of units sent by from, with an
+// optional comment in the forward payload.
+func jettonPayment(lt uint64, from string, units int64, comment string) transaction {
+	sender, _ := ParseAddress(payer)
+	body := cell.BeginCell().
+		MustStoreUInt(opTransferNotification, 32).
+		MustStoreUInt(0, 64).
+		MustStoreBigCoins(big.NewInt(units)).
+		MustStoreAddr(sender)
+	if comment == "" {
+		body.MustStoreBoolBit(false)
+	} else {
+		payload := cell.BeginCell().MustStoreUInt(opComment, 32)
+		payload.MustStoreStringSnake(comment)
+		body.MustStoreBoolBit(true).MustStoreRef(payload.EndCell())
+	}
+
+	in := message{Source: from, Value: "50000000"}
+	in.MsgData.Type = "msg.dataRaw"
+	in.MsgData.Body = base64.StdEncoding.EncodeToString(body.EndCell().ToBOC())
+	return transactionAt(lt, in)
+}
+
+func newTestAdapter(t *testing.T, merchantAddress string, store chains.Store) (*Adapter, *fakeToncenter) {
+	t.Helper()
+	node, url := newFakeToncenter(t)
+	a, err := New(Config{
+		RPCURL:          url,
+		Tokens:          []chains.Token{ton, usdt},
+		Confirmations:   1,
+		MerchantAddress: merchantAddress,
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, node
+}
+
+func TestSameAddress(t *testing.T) {
+	addr, _ := ParseAddress(payer)
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"bounceable and non-bounceable", FriendlyAddress(addr, true), FriendlyAddress(addr, false), true},
+		{"raw form", fmt.Sprintf("0:%x", addr.Data()), payer, true},
+		{"standard base64", payer, base64.StdEncoding.EncodeToString(mustDecode(t, payer)), true},
+		{"different accounts", payer, impostor, false},
+		{"invalid", payer, "not an address", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameAddress(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameAddress(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustDecode(t *testing.T, friendly string) []byte {
+	raw, err := base64.URLEncoding.DecodeString(friendly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestCheckPayment(t *testing.T) {
+	bounce := tonPayment(10, "2000000000", "")
+	bounce.OutMsgs = []message{{Destination: payer, Value: "1999000000"}}
+
+	tests := []struct {
+		name   string
+		tx     transaction
+		token  chains.Token
+		amount string
+		want   string
+	}{
+		{"TON payment", tonPayment(10, "2000000000", ""), ton, "2", "2"},
+		{"TON overpayment", tonPayment(10, "2500000000", ""), ton, "2", "2.5"},
+		{"TON underpayment", tonPayment(10, "1999999999", ""), ton, "2", ""},
+		{"bounced TON payment", bounce, ton, "2", ""},
+		{"jetton payment", jettonPayment(10, jettonWallet, 25_000_000, ""), usdt, "25", "25"},
+		{"jetton underpayment", jettonPayment(10, jettonWallet, 24_999_999, ""), usdt, "25", ""},
+		{"notification from another contract", jettonPayment(10, impostor, 25_000_000, ""), usdt, "25", ""},
+		{"TON attached to a jetton notification", jettonPayment(10, jettonWallet, 25_000_000, ""), ton, "0.01", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, node := newTestAdapter(t, "", nil)
+			node.receive(depositAddress, tt.tx)
+
+			tx, err := a.CheckPayment(context.Background(), chains.Deposit{
+				Address: depositAddress,
+				Token:   tt.token,
+				Amount:  decimal.RequireFromString(tt.amount),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if tx != nil {
+					t.Fatalf("CheckPayment() = %+v, want none", tx)
+				}
+				return
+			}
+			if tx == nil {
+				t.Fatal("payment not found")
+			}
+			if !tx.Amount.Equal(decimal.RequireFromString(tt.want)) || tx.FromAddress != payer || tx.BlockNumber != 10 {
+				t.Errorf("CheckPayment() = %+v, want %s from %s at lt 10", tx, tt.want, payer)
+			}
+		})
+	}
+}
+
+func TestMerchantPaymentsByReference(t *testing.T) {
+	tests := []struct {
+		name  string
+		token chains.Token
+		tx    func(lt uint64, reference string) transaction
+	}{
+		{"TON", ton, func(lt uint64, reference string) transaction {
+			return tonPayment(lt, "2000000000", reference)
+		}},
+		{"jetton", usdt, func(lt uint64, reference string) transaction {
+			return jettonPayment(lt, jettonWallet, 2_000_000, reference)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := chaintest.NewStore()
+			store.SaveCursor(models.ChainTON, chains.Cursor{Height: 1000})
+			cursor := func() uint64 {
+				c, _ := store.LoadCursor(models.ChainTON)
+				return c.Height
+			}
+			a, node := newTestAdapter(t, merchant, store)
+
+			// The payment is followed by more transactions than fit a page
+			node.receive(merchant, tonPayment(1000, "1", "processed"))
+			node.receive(merchant, tt.tx(1001, "other-payment"))
+			node.receive(merchant, tt.tx(1002, "payment-1"))
+			for lt := uint64(1003); lt < 1003+2*merchantPageSize; lt++ {
+				node.receive(merchant, tonPayment(lt, "1", fmt.Sprintf("noise-%d", lt)))
+			}
+			newest := uint64(1003 + 2*merchantPageSize - 1)
+
+			deposit := chains.Deposit{
+				Address:   merchant,
+				Token:     tt.token,
+				Amount:    decimal.NewFromInt(2),
+				Reference: "payment-1",
+			}
+			syncDeposits := func(a *Adapter) {
+				t.Helper()
+				if err := a.Sync(context.Background(), []chains.Deposit{deposit}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			syncDeposits(a)
+			if cursor() != 1001 {
+				t.Fatalf("cursor = %d, want 1001 before the untaken payment", cursor())
+			}
+
+			// A restart collects the payment again
+			restarted, err := New(a.config, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			syncDeposits(restarted)
+
+			tx, err := restarted.CheckPayment(context.Background(), deposit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx == nil || tx.BlockNumber != 1002 || tx.ToAddress != merchant {
+				t.Fatalf("CheckPayment() = %+v, want the transfer at lt 1002", tx)
+			}
+			if tx, _ := restarted.CheckPayment(context.Background(), deposit); tx != nil {
+				t.Fatal("payment matched twice")
+			}
+
+			calls := node.callCount()
+			syncDeposits(restarted)
+			if cursor() != newest {
+				t.Errorf("cursor = %d, want the newest transaction %d", cursor(), newest)
+			}
+			if node.callCount()-calls != 1 {
+				t.Errorf("caught-up sync made %d calls, want 1", node.callCount()-calls)
+			}
+		})
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	tests := []struct {
+		name     string
+		received bool
+		want     int
+	}{
+		{"served", true, 1},
+		{"not served yet", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, node := newTestAdapter(t, "", nil)
+			if tt.received {
+				node.receive(depositAddress, tonPayment(10, "1", ""))
+			}
+
+			got, err := a.Confirmations(context.Background(), &models.Transaction{
+				TxHash:      "hash-10",
+				ToAddress:   depositAddress,
+				BlockNumber: 10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Confirmations() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
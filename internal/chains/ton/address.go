@@ -0,0 +1,48 @@
+package ton
+
+import (
+	"crypto/ed25519"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+)
+
+// WalletAddress computes the address of the wallet v4R2 contract owned by
+// publicKey: the hash of its state init (v4R2 code plus a data cell holding
+// seqno, the default subwallet id and the key) in the basechain.
+func WalletAddress(publicKey ed25519.PublicKey) (*address.Address, error) {
+	return wallet.AddressFromPubKey(publicKey, wallet.V4R2, wallet.DefaultSubwallet)
+}
+
+// FriendlyAddress encodes addr in the base64url user-friendly form.
+// Undeployed deposit wallets should be paid to the non-bounceable form, or
+// the transfer bounces back to the sender.
+func FriendlyAddress(addr *address.Address, bounceable bool) string {
+	return addr.Bounce(bounceable).String()
+}
+
+// ParseAddress parses a user-friendly address, validating its CRC16
+// checksum, or a raw "workchain:hex" address. Both the base64url and the
+// standard base64 alphabets are accepted.
+func ParseAddress(s string) (*address.Address, error) {
+	if strings.Contains(s, ":") {
+		return address.ParseRawAddr(s)
+	}
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return address.ParseAddr(s)
+}
+
+// SameAddress reports whether a and b refer to the same account, whatever
+// form they are written in.
+func SameAddress(a, b string) bool {
+	addrA, err := ParseAddress(a)
+	if err != nil {
+		return false
+	}
+	addrB, err := ParseAddress(b)
+	if err != nil {
+		return false
+	}
+	return addrA.Workchain() == addrB.Workchain() && string(addrA.Data()) == string(addrB.Data())
+}
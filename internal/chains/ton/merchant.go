@@ -0,0 +1,177 @@
+package ton
+
+import (
+	"context"
+	"log"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+)
+
+// A shared merchant address receives every payment, so looking at a fixed
+// number of its latest transactions can miss a payment under load. Instead
+// its history is paged back to the last transaction already processed,
+// persisted as a cursor of logical time and hash, and incoming transfers
+// are held by reference until CheckPayment matches them.
+//
+// As with the EVM scanner, the persisted cursor never moves past a held
+// transfer, so one that was not matched yet is collected again after a
+// restart.
+const (
+	merchantPageSize = 100
+
+	// maxMerchantPages bounds how far back a single Sync pages, e.g. after
+	// long downtime; payments are short-lived, so older transactions are
+	// of no use anyway.
+	maxMerchantPages = 20
+)
+
+// scanMerchant collects the merchant address transactions since the last
+// scan that carry one of references.
+func (a *Adapter) scanMerchant(ctx context.Context, references map[string]bool) error {
+	a.pruneReferenced(references)
+
+	cursor := a.position
+	if cursor == nil {
+		var err error
+		cursor, err = a.store.LoadCursor(models.ChainTON)
+		if err != nil {
+			return err
+		}
+	}
+
+	var newest *chains.Cursor
+	var lt, hash string
+	reached := false
+	for page := 0; page < maxMerchantPages && !reached; page++ {
+		txs, err := a.rpc.getTransactions(ctx, a.config.MerchantAddress, merchantPageSize, lt, hash)
+		if err != nil {
+			return err
+		}
+		full := len(txs) == merchantPageSize
+		if lt != "" && len(txs) > 0 {
+			// Each page starts with the last transaction of the previous one
+			txs = txs[1:]
+		}
+
+		for i := range txs {
+			tx := &txs[i]
+			id, ok := logicalTime(tx)
+			if !ok {
+				continue
+			}
+			if cursor != nil && id <= cursor.Height {
+				reached = true
+				break
+			}
+			if newest == nil {
+				newest = &chains.Cursor{Height: id, Hash: tx.TransactionID.Hash}
+			}
+			a.hold(tx, references)
+		}
+
+		if cursor == nil {
+			// The first scan starts at the latest transactions
+			reached = true
+		}
+		if reached || !full || len(txs) == 0 {
+			reached = true
+			break
+		}
+		last := txs[len(txs)-1].TransactionID
+		lt, hash = last.LT, last.Hash
+	}
+	if !reached {
+		log.Printf("TON merchant address has more than %d new transactions, skipping older ones", maxMerchantPages*(merchantPageSize-1))
+	}
+
+	if newest != nil {
+		a.position = newest
+	} else if a.position == nil {
+		a.position = cursor
+	}
+	if a.position == nil {
+		return nil
+	}
+	return a.store.SaveCursor(models.ChainTON, a.checkpoint())
+}
+
+// hold keeps an incoming transfer whose comment is one of references.
+func (a *Adapter) hold(tx *transaction, references map[string]bool) {
+	if tx.InMsg == nil || tx.InMsg.Source == "" || bounced(tx) {
+		return
+	}
+	reference := transferReference(tx)
+	if !references[reference] {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.referenced[reference] = append(a.referenced[reference], tx)
+}
+
+// transferReference returns the comment of tx's incoming message, or that
+// of the forward payload for a jetton transfer notification.
+func transferReference(tx *transaction) string {
+	body := tx.InMsg.body()
+	if body == nil {
+		return tx.InMsg.comment()
+	}
+	if op, err := body.LoadUInt(32); err != nil || op != opTransferNotification {
+		return tx.InMsg.comment()
+	}
+	if _, err := body.LoadUInt(64); err != nil {
+		return ""
+	}
+	if _, err := body.LoadBigCoins(); err != nil {
+		return ""
+	}
+	if _, err := body.LoadAddr(); err != nil {
+		return ""
+	}
+	return forwardComment(body)
+}
+
+// takeReferenced returns and forgets a held transfer paying deposit.
+func (a *Adapter) takeReferenced(deposit chains.Deposit, jettonWallet string) *models.Transaction {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	held := a.referenced[deposit.Reference]
+	for i, tx := range held {
+		if transfer := matchTransfer(tx, deposit, jettonWallet); transfer != nil {
+			a.referenced[deposit.Reference] = append(held[:i], held[i+1:]...)
+			return transfer
+		}
+	}
+	return nil
+}
+
+// pruneReferenced drops held transfers whose payment is no longer awaited.
+func (a *Adapter) pruneReferenced(references map[string]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for reference := range a.referenced {
+		if !references[reference] {
+			delete(a.referenced, reference)
+		}
+	}
+}
+
+// checkpoint returns the cursor to persist: the newest transaction seen,
+// held back to just before the oldest held transfer.
+func (a *Adapter) checkpoint() chains.Cursor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	checkpoint := *a.position
+	for _, held := range a.referenced {
+		for _, tx := range held {
+			if id, ok := logicalTime(tx); ok && id <= checkpoint.Height {
+				checkpoint = chains.Cursor{Height: id - 1}
+			}
+		}
+	}
+	return checkpoint
+}
@@ -0,0 +1,214 @@
+package ton
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// rpcClient is a minimal client of the toncenter v2 JSON-RPC endpoint.
+type rpcClient struct {
+	url    string
+	apiKey string
+	http   *http.Client
+	nextID atomic.Uint64
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+	Code   int             `json:"code"`
+}
+
+func newRPCClient(url, apiKey string) *rpcClient {
+	return &rpcClient{
+		url:    url,
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, result interface{}, params map[string]interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// toncenter reports errors in the body with a matching HTTP status
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("toncenter returned status %d: %w", resp.StatusCode, err)
+	}
+	if !response.OK {
+		return fmt.Errorf("toncenter error %d: %s", response.Code, response.Error)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+type transactionID struct {
+	LT   string `json:"lt"`
+	Hash string `json:"hash"`
+}
+
+type transaction struct {
+	Utime         int64         `json:"utime"`
+	TransactionID transactionID `json:"transaction_id"`
+	InMsg         *message      `json:"in_msg"`
+	OutMsgs       []message     `json:"out_msgs"`
+}
+
+type message struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Value       string `json:"value"`
+	MsgData     struct {
+		Type string `json:"@type"`
+		Body string `json:"body"`
+		Text string `json:"text"`
+	} `json:"msg_data"`
+}
+
+// comment returns the message's text comment, or "" if it has none.
+// toncenter decodes plain comments into msg.dataText.
+func (m *message) comment() string {
+	if m.MsgData.Type == "msg.dataText" {
+		text, err := base64.StdEncoding.DecodeString(m.MsgData.Text)
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	}
+	if body := m.body(); body != nil {
+		return textComment(body)
+	}
+	return ""
+}
+
+// body parses the message body, or returns nil if the message carries none
+// or only a text comment.
+func (m *message) body() *cell.Slice {
+	if m.MsgData.Type != "msg.dataRaw" || m.MsgData.Body == "" {
+		return nil
+	}
+	boc, err := base64.StdEncoding.DecodeString(m.MsgData.Body)
+	if err != nil {
+		return nil
+	}
+	root, err := cell.FromBOC(boc)
+	if err != nil {
+		return nil
+	}
+	return root.BeginParse()
+}
+
+// getTransactions returns up to limit transactions of address, newest
+// first. With lt and hash, the list starts at that transaction instead of
+// the latest one, which is how toncenter pages back through history.
+func (c *rpcClient) getTransactions(ctx context.Context, address string, limit int, lt, hash string) ([]transaction, error) {
+	params := map[string]interface{}{
+		"address":  address,
+		"limit":    limit,
+		"archival": true,
+	}
+	if lt != "" {
+		params["lt"] = lt
+		params["hash"] = hash
+	}
+
+	var result []transaction
+	err := c.call(ctx, "getTransactions", &result, params)
+	return result, err
+}
+
+// getTransaction looks up a single transaction of address by its logical
+// time and hash. It returns nil if the node does not know it.
+func (c *rpcClient) getTransaction(ctx context.Context, address, lt, hash string) (*transaction, error) {
+	result, err := c.getTransactions(ctx, address, 1, lt, hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 || result[0].TransactionID.Hash != hash {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+// getJettonWallet asks a jetton master for the jetton wallet of owner.
+func (c *rpcClient) getJettonWallet(ctx context.Context, master string, owner *address.Address) (*address.Address, error) {
+	arg := cell.BeginCell().MustStoreAddr(owner).EndCell().ToBOC()
+
+	var result struct {
+		ExitCode int               `json:"exit_code"`
+		Stack    []json.RawMessage `json:"stack"`
+	}
+	err := c.call(ctx, "runGetMethod", &result, map[string]interface{}{
+		"address": master,
+		"method":  "get_wallet_address",
+		"stack": [][]interface{}{
+			{"tvm.Slice", base64.StdEncoding.EncodeToString(arg)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 || len(result.Stack) != 1 {
+		return nil, fmt.Errorf("get_wallet_address on %s failed with exit code %d", master, result.ExitCode)
+	}
+
+	// The address comes back as a ["cell", {"bytes": <BOC>}] stack entry
+	var entry []json.RawMessage
+	if err := json.Unmarshal(result.Stack[0], &entry); err != nil || len(entry) != 2 {
+		return nil, fmt.Errorf("unexpected get_wallet_address result from %s", master)
+	}
+	var value struct {
+		Bytes string `json:"bytes"`
+	}
+	if err := json.Unmarshal(entry[1], &value); err != nil {
+		return nil, err
+	}
+	boc, err := base64.StdEncoding.DecodeString(value.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	root, err := cell.FromBOC(boc)
+	if err != nil {
+		return nil, err
+	}
+	return root.BeginParse().LoadAddr()
+}
@@ -0,0 +1,271 @@
+package tron
+
+import (
+	"context"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+// accountPath is the BIP44 account the configured xpub is expected to be
+// exported at.
+const accountPath = "m/44'/195'/0'"
+
+// transferLimit bounds how many recent transfers are inspected per deposit
+// address on each check.
+const transferLimit = 50
+
+// droppedAfter is how long a detected transaction may stay unknown to the
+// node before it is considered dropped. The sender sets a transaction's
+// expiration, which the network caps at 24 hours after its reference
+// block, so one that is still unknown a day after detection can no longer
+// make it into a block.
+const droppedAfter = 24 * time.Hour
+
+// Config configures the Tron adapter.
+type Config struct {
+	APIURL        string
+	APIKey        string
+	XPub          string
+	Tokens        []chains.Token // TRX plus TRC-20 tokens
+	Confirmations int
+}
+
+// Adapter accepts TRX and TRC-20 stablecoin payments on Tron.
+type Adapter struct {
+	config Config
+	store  chains.Store
+	api    *apiClient
+	xpub   *hdkeychain.ExtendedKey
+}
+
+func New(cfg Config, store chains.Store) (*Adapter, error) {
+	a := &Adapter{
+		config: cfg,
+		store:  store,
+		api:    newAPIClient(cfg.APIURL, cfg.APIKey),
+	}
+
+	if cfg.XPub != "" {
+		xpub, err := chains.ParseAccountXPub(cfg.XPub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRON_XPUB: %w", err)
+		}
+		a.xpub = xpub
+	}
+
+	return a, nil
+}
+
+func (a *Adapter) Chain() models.Chain {
+	return models.ChainTron
+}
+
+func (a *Adapter) NewAddress(req chains.AddressRequest) (*chains.Wallet, error) {
+	if a.xpub != nil {
+		return a.deriveAddress()
+	}
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:    PublicKeyAddress(privateKey.PublicKey),
+		PrivateKey: fmt.Sprintf("%x", crypto.FromECDSA(privateKey)),
+		Chain:      models.ChainTron,
+	}, nil
+}
+
+// DerivesPublicly reports whether a TRON_XPUB was configured.
+func (a *Adapter) DerivesPublicly() bool {
+	return a.xpub != nil
+}
+
+func (a *Adapter) deriveAddress() (*chains.Wallet, error) {
+	index, err := a.store.NextIndex(string(models.ChainTron))
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := chains.DeriveChild(a.xpub, index)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &chains.Wallet{
+		Address:         PublicKeyAddress(*publicKey.ToECDSA()),
+		Chain:           models.ChainTron,
+		DerivationIndex: index,
+		DerivationPath:  fmt.Sprintf("%s/0/%d", accountPath, index),
+	}, nil
+}
+
+func (a *Adapter) ValidateAddress(address string) error {
+	if _, err := DecodeAddress(address); err != nil {
+		return fmt.Errorf("invalid Tron address %q: %w", address, err)
+	}
+	return nil
+}
+
+func (a *Adapter) Tokens() []chains.Token {
+	return a.config.Tokens
+}
+
+func (a *Adapter) Sync(ctx context.Context, deposits []chains.Deposit) error {
+	return nil
+}
+
+// CheckPayment looks through the deposit address's recent incoming TRX
+// transfers, or the TRC-20 Transfer events of the token's contract, for one
+// of at least the expected amount.
+func (a *Adapter) CheckPayment(ctx context.Context, deposit chains.Deposit) (*models.Transaction, error) {
+	expected := deposit.Amount.Shift(int32(deposit.Token.Decimals))
+
+	var transfer *models.Transaction
+	var err error
+	if deposit.Token.Token == models.TokenNative {
+		transfer, err = a.nativeTransfer(ctx, deposit.Address, expected)
+	} else if deposit.Token.Contract != "" {
+		transfer, err = a.tokenTransfer(ctx, deposit.Address, deposit.Token.Contract, expected)
+	}
+	if err != nil || transfer == nil {
+		return nil, err
+	}
+
+	transfer.Chain = models.ChainTron
+	transfer.ToAddress = deposit.Address
+	transfer.Token = deposit.Token.Token
+	transfer.Amount = transfer.Amount.Shift(-int32(deposit.Token.Decimals))
+	return transfer, nil
+}
+
+// nativeTransfer finds a successful TransferContract paying address at
+// least expected sun.
+func (a *Adapter) nativeTransfer(ctx context.Context, address string, expected decimal.Decimal) (*models.Transaction, error) {
+	txs, err := a.api.transactions(ctx, address, transferLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range txs {
+		if len(tx.Ret) == 0 || tx.Ret[0].ContractRet != "SUCCESS" || len(tx.RawData.Contract) != 1 {
+			continue
+		}
+
+		contract := tx.RawData.Contract[0]
+		if contract.Type != "TransferContract" {
+			continue
+		}
+		value := contract.Parameter.Value
+		to, err := hexToAddress(value.ToAddress)
+		if err != nil || to != address {
+			continue
+		}
+
+		amount := decimal.NewFromInt(value.Amount)
+		if amount.LessThan(expected) {
+			continue
+		}
+
+		from, _ := hexToAddress(value.OwnerAddress)
+		return &models.Transaction{
+			TxHash:      tx.TxID,
+			FromAddress: from,
+			Amount:      amount,
+			BlockNumber: tx.BlockNumber,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// tokenTransfer finds a TRC-20 Transfer event of contract paying address at
+// least expected base units.
+func (a *Adapter) tokenTransfer(ctx context.Context, address, contract string, expected decimal.Decimal) (*models.Transaction, error) {
+	transfers, err := a.api.trc20Transfers(ctx, address, contract, transferLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range transfers {
+		if transfer.Type != "Transfer" || transfer.To != address || transfer.TokenInfo.Address != contract {
+			continue
+		}
+
+		amount, err := decimal.NewFromString(transfer.Value)
+		if err != nil || amount.LessThan(expected) {
+			continue
+		}
+
+		return &models.Transaction{
+			TxHash:        transfer.TransactionID,
+			FromAddress:   transfer.From,
+			Amount:        amount,
+			TokenContract: contract,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// Confirmations returns how many blocks deep tx is. A transaction whose
+// contract call failed, e.g. a reverted TRC-20 transfer, is reported as
+// dropped, as is one the node does not know although its block has been
+// solidified or it was detected long ago.
+func (a *Adapter) Confirmations(ctx context.Context, tx *models.Transaction) (int, error) {
+	info, err := a.api.transactionInfo(ctx, tx.TxHash)
+	if err != nil {
+		return 0, err
+	}
+	if info.ID == "" {
+		return 0, a.unknownTransaction(ctx, tx)
+	}
+	if result := info.Receipt.Result; result != "" && result != "SUCCESS" {
+		return 0, chains.ErrTransactionDropped
+	}
+	tx.BlockNumber = info.BlockNumber
+
+	head, err := a.api.headBlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if head < tx.BlockNumber {
+		return 0, nil
+	}
+	return int(head-tx.BlockNumber) + 1, nil
+}
+
+// unknownTransaction returns chains.ErrTransactionDropped if tx should be
+// known to the node by now: its block was solidified, or, for transfers
+// detected without a block number, the grace period has passed.
+func (a *Adapter) unknownTransaction(ctx context.Context, tx *models.Transaction) error {
+	if tx.BlockNumber != 0 {
+		solid, err := a.api.solidBlock(ctx)
+		if err != nil {
+			return err
+		}
+		if solid >= tx.BlockNumber {
+			return chains.ErrTransactionDropped
+		}
+	}
+	if !tx.CreatedAt.IsZero() && time.Since(tx.CreatedAt) > droppedAfter {
+		return chains.ErrTransactionDropped
+	}
+	return nil
+}
+
+func (a *Adapter) RequiredConfirmations() int {
+	return a.config.Confirmations
+}
@@ -0,0 +1,275 @@
+package tron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/chaintest"
+	"multi-chain-payment-gateway/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+)
+
+// Account xpub at m/44'/195'/0' of the BIP39 test mnemonic "abandon
+// abandon ... about"; its first address is a well-known vector.
+const testXPub = "xpub6D1AabNHCupeiLM65ZR9UStMhJ1vCpyV4XbZdyhMZBiJXALQtmn9p42VTQckoHVn8WNqS7dqnJokZHAHcHGoaQgmv8D45oNUKx6DZMNZBCd"
+
+var (
+	depositAddress = EncodeAddress(common.HexToAddress("0x00000000000000000000000000000000000000d1"))
+	payer          = EncodeAddress(common.HexToAddress("0x00000000000000000000000000000000000000e1"))
+
+	trx  = chains.Token{Token: models.TokenNative, Symbol: "TRX", Decimals: 6}
+	usdt = chains.Token{Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6, Contract: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"}
+)
+
+// hexAddress returns the "41..." form the node API uses.
+func hexAddress(address string) string {
+	account, _ := DecodeAddress(address)
+	return "41" + strings.TrimPrefix(strings.ToLower(account.Hex()), "0x")
+}
+
+func TestDeriveAddress(t *testing.T) {
+	a, err := New(Config{XPub: testXPub}, chaintest.NewStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet, err := a.NewAddress(chains.AddressRequest{PaymentID: "p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Address != "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH" || wallet.DerivationPath != "m/44'/195'/0'/0/0" {
+		t.Errorf("NewAddress() = %s at %s", wallet.Address, wallet.DerivationPath)
+	}
+	if wallet.PrivateKey != "" {
+		t.Error("derived address carries a private key")
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{"TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH", true},
+		{"TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdh", false}, // checksum
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", false}, // Bitcoin version byte
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94", false},
+	}
+	a, _ := New(Config{}, nil)
+	for _, tt := range tests {
+		if err := a.ValidateAddress(tt.address); (err == nil) != tt.valid {
+			t.Errorf("ValidateAddress(%q) = %v, want valid %v", tt.address, err, tt.valid)
+		}
+	}
+}
+
+// newFakeTronGrid returns an adapter on a stand-in API answering the paths
+// in responses.
+func newFakeTronGrid(t *testing.T, responses map[string]interface{}) *Adapter {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected call to %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	a, err := New(Config{APIURL: server.URL, Tokens: []chains.Token{trx, usdt}, Confirmations: 19}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func trxTransfer(id string, to string, sun int64, result string) map[string]interface{} {
+	return map[string]interface{}{
+		"txID":        id,
+		"blockNumber": 1000,
+		"ret":         []interface{}{map[string]string{"contractRet": result}},
+		"raw_data": map[string]interface{}{
+			"contract": []interface{}{map[string]interface{}{
+				"type": "TransferContract",
+				"parameter": map[string]interface{}{"value": map[string]interface{}{
+					"amount":        sun,
+					"owner_address": hexAddress(payer),
+					"to_address":    hexAddress(to),
+				}},
+			}},
+		},
+	}
+}
+
+func trc20Event(id, contract, to, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"transaction_id": id,
+		"from":           payer,
+		"to":             to,
+		"type":           "Transfer",
+		"value":          value,
+		"token_info":     map[string]interface{}{"address": contract, "decimals": 6},
+	}
+}
+
+func TestCheckPayment(t *testing.T) {
+	other := EncodeAddress(common.HexToAddress("0x00000000000000000000000000000000000000d2"))
+	tests := []struct {
+		name   string
+		txs    []interface{}
+		events []interface{}
+		token  chains.Token
+		want   string
+	}{
+		{
+			name:  "TRX payment",
+			txs:   []interface{}{trxTransfer("a1", depositAddress, 10_000_000, "SUCCESS")},
+			token: trx,
+			want:  "a1",
+		},
+		{
+			name:  "failed TRX transfer",
+			txs:   []interface{}{trxTransfer("a1", depositAddress, 10_000_000, "REVERT")},
+			token: trx,
+		},
+		{
+			name: "TRX underpayment before a payment",
+			txs: []interface{}{
+				trxTransfer("a1", depositAddress, 9_999_999, "SUCCESS"),
+				trxTransfer("a2", depositAddress, 10_000_000, "SUCCESS"),
+			},
+			token: trx,
+			want:  "a2",
+		},
+		{
+			name:  "TRX sent elsewhere",
+			txs:   []interface{}{trxTransfer("a1", other, 10_000_000, "SUCCESS")},
+			token: trx,
+		},
+		{
+			name:   "USDT payment",
+			events: []interface{}{trc20Event("b1", usdt.Contract, depositAddress, "10000000")},
+			token:  usdt,
+			want:   "b1",
+		},
+		{
+			name:   "USDT underpayment",
+			events: []interface{}{trc20Event("b1", usdt.Contract, depositAddress, "9999999")},
+			token:  usdt,
+		},
+		{
+			name:   "transfer of another contract",
+			events: []interface{}{trc20Event("b1", "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8", depositAddress, "10000000")},
+			token:  usdt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/v1/accounts/" + depositAddress + "/transactions"
+			response := map[string]interface{}{"success": true, "data": tt.txs}
+			if tt.token.Token != models.TokenNative {
+				path += "/trc20"
+				response["data"] = tt.events
+			}
+			a := newFakeTronGrid(t, map[string]interface{}{path: response})
+
+			tx, err := a.CheckPayment(context.Background(), chains.Deposit{
+				Address: depositAddress,
+				Token:   tt.token,
+				Amount:  decimal.NewFromInt(10),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if tx != nil {
+					t.Fatalf("CheckPayment() = %+v, want none", tx)
+				}
+				return
+			}
+			if tx == nil || tx.TxHash != tt.want || tx.FromAddress != payer || tx.ToAddress != depositAddress {
+				t.Fatalf("CheckPayment() = %+v, want %s from %s", tx, tt.want, payer)
+			}
+		})
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	block := func(number int) map[string]interface{} {
+		return map[string]interface{}{"block_header": map[string]interface{}{"raw_data": map[string]interface{}{"number": number}}}
+	}
+
+	tests := []struct {
+		name     string
+		info     map[string]interface{}
+		block    uint64
+		detected time.Duration
+		want     int
+		wantErr  error
+	}{
+		{
+			name:  "in a block",
+			info:  map[string]interface{}{"id": "t1", "blockNumber": 1000},
+			block: 1000,
+			want:  21,
+		},
+		{
+			name:    "reverted",
+			info:    map[string]interface{}{"id": "t1", "blockNumber": 1000, "receipt": map[string]string{"result": "REVERT"}},
+			block:   1000,
+			wantErr: chains.ErrTransactionDropped,
+		},
+		{
+			name:  "unknown in an unsolidified block",
+			info:  map[string]interface{}{},
+			block: 1005,
+		},
+		{
+			name:    "unknown in a solidified block",
+			info:    map[string]interface{}{},
+			block:   1000,
+			wantErr: chains.ErrTransactionDropped,
+		},
+		{
+			name:     "unknown within the expiration window",
+			info:     map[string]interface{}{},
+			detected: time.Hour,
+		},
+		{
+			name:     "unknown long after detection",
+			info:     map[string]interface{}{},
+			detected: droppedAfter + time.Minute,
+			wantErr:  chains.ErrTransactionDropped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeTronGrid(t, map[string]interface{}{
+				"/wallet/gettransactioninfobyid": tt.info,
+				"/wallet/getnowblock":            block(1020),
+				"/walletsolidity/getnowblock":    block(1001),
+			})
+
+			tx := &models.Transaction{TxHash: "t1", BlockNumber: tt.block, CreatedAt: time.Now().Add(-tt.detected)}
+			got, err := a.Confirmations(context.Background(), tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirmations() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Confirmations() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
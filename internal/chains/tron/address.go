@@ -0,0 +1,52 @@
+package tron
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// addressPrefix is the version byte of mainnet Tron addresses, which makes
+// their base58check form start with "T".
+const addressPrefix = 0x41
+
+// PublicKeyAddress returns the base58check address of a secp256k1 public
+// key: the prefix byte followed by the same 20 bytes an Ethereum address
+// would have.
+func PublicKeyAddress(publicKey ecdsa.PublicKey) string {
+	return EncodeAddress(crypto.PubkeyToAddress(publicKey))
+}
+
+// EncodeAddress returns the base58check form of a 20-byte account.
+func EncodeAddress(account common.Address) string {
+	return base58.CheckEncode(account.Bytes(), addressPrefix)
+}
+
+// DecodeAddress parses a base58check Tron address into its 20-byte account.
+func DecodeAddress(address string) (common.Address, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if version != addressPrefix || len(payload) != common.AddressLength {
+		return common.Address{}, fmt.Errorf("not a Tron account address")
+	}
+	return common.BytesToAddress(payload), nil
+}
+
+// hexToAddress converts the "41..." hex form the node API uses for
+// addresses into base58check.
+func hexToAddress(s string) (string, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) != common.AddressLength+1 || raw[0] != addressPrefix {
+		return "", fmt.Errorf("not a Tron account address: %s", s)
+	}
+	return EncodeAddress(common.BytesToAddress(raw[1:])), nil
+}
@@ -0,0 +1,177 @@
+package tron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiClient is a minimal client of a TronGrid-compatible HTTP API.
+type apiClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newAPIClient(baseURL, apiKey string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tron api returned status %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+type trc20Transfer struct {
+	TransactionID string `json:"transaction_id"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Type          string `json:"type"`
+	Value         string `json:"value"`
+	TokenInfo     struct {
+		Address  string `json:"address"`
+		Decimals int    `json:"decimals"`
+	} `json:"token_info"`
+}
+
+// trc20Transfers returns recent Transfer events of contract received by
+// address, newest first.
+func (c *apiClient) trc20Transfers(ctx context.Context, address, contract string, limit int) ([]trc20Transfer, error) {
+	query := url.Values{
+		"only_to":          {"true"},
+		"contract_address": {contract},
+		"limit":            {fmt.Sprint(limit)},
+	}
+
+	var result struct {
+		Data    []trc20Transfer `json:"data"`
+		Success bool            `json:"success"`
+	}
+	err := c.do(ctx, "GET", "/v1/accounts/"+address+"/transactions/trc20?"+query.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("tron api failed to list TRC-20 transfers of %s", address)
+	}
+	return result.Data, nil
+}
+
+type transaction struct {
+	TxID        string `json:"txID"`
+	BlockNumber uint64 `json:"blockNumber"`
+	Ret         []struct {
+		ContractRet string `json:"contractRet"`
+	} `json:"ret"`
+	RawData struct {
+		Contract []struct {
+			Type      string `json:"type"`
+			Parameter struct {
+				Value struct {
+					Amount       int64  `json:"amount"`
+					OwnerAddress string `json:"owner_address"`
+					ToAddress    string `json:"to_address"`
+				} `json:"value"`
+			} `json:"parameter"`
+		} `json:"contract"`
+	} `json:"raw_data"`
+}
+
+// transactions returns recent transactions received by address, newest
+// first.
+func (c *apiClient) transactions(ctx context.Context, address string, limit int) ([]transaction, error) {
+	query := url.Values{
+		"only_to": {"true"},
+		"limit":   {fmt.Sprint(limit)},
+	}
+
+	var result struct {
+		Data    []transaction `json:"data"`
+		Success bool          `json:"success"`
+	}
+	err := c.do(ctx, "GET", "/v1/accounts/"+address+"/transactions?"+query.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("tron api failed to list transactions of %s", address)
+	}
+	return result.Data, nil
+}
+
+type transactionInfo struct {
+	ID          string `json:"id"`
+	BlockNumber uint64 `json:"blockNumber"`
+	Receipt     struct {
+		Result string `json:"result"`
+	} `json:"receipt"`
+}
+
+// transactionInfo returns the execution result of a transaction. Its ID is
+// empty if the transaction is not in a block yet.
+func (c *apiClient) transactionInfo(ctx context.Context, txID string) (*transactionInfo, error) {
+	var info transactionInfo
+	err := c.do(ctx, "POST", "/wallet/gettransactioninfobyid", map[string]string{"value": txID}, &info)
+	return &info, err
+}
+
+func (c *apiClient) headBlock(ctx context.Context) (uint64, error) {
+	return c.nowBlock(ctx, "/wallet/getnowblock")
+}
+
+// solidBlock returns the latest solidified block, which can no longer be
+// reverted.
+func (c *apiClient) solidBlock(ctx context.Context) (uint64, error) {
+	return c.nowBlock(ctx, "/walletsolidity/getnowblock")
+}
+
+func (c *apiClient) nowBlock(ctx context.Context, path string) (uint64, error) {
+	var block struct {
+		BlockHeader struct {
+			RawData struct {
+				Number uint64 `json:"number"`
+			} `json:"raw_data"`
+		} `json:"block_header"`
+	}
+	err := c.do(ctx, "POST", path, map[string]string{}, &block)
+	return block.BlockHeader.RawData.Number, err
+}
@@ -1,17 +1,72 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Environment    string
 	Port          string
 	DatabaseURL   string
-	EthereumRPC   string
+	EthereumXPub  string // shared by all EVM networks
 	SolanaRPC     string
+	SolanaSeed    string
+	SolanaUSDC    string
+	SolanaUSDT    string
 	TonRPC        string
+	TonAPIKey     string
+	TonUSDC       string
+	TonUSDT       string
+	BitcoinRPC    string // Esplora REST API base URL
+	BitcoinXPub   string
+	BitcoinNet    string
+	TronAPI       string // TronGrid-compatible HTTP API base URL
+	TronAPIKey    string
+	TronXPub      string
+	TronUSDC      string
+	TronUSDT      string
+	LNDURL        string // LND REST endpoint; Lightning is disabled if empty
+	LNDMacaroon   string // hex-encoded invoice macaroon
+	LNDCertPath   string
 	PriceAPIKey   string
+	KeystorePass  string // encrypts deposit address keys at rest
 	WebhookSecret string
 	WidgetBaseURL string
+
+	// How the merchant is presented by wallets opening a payment request
+	MerchantName    string
+	MerchantIconURL string // PNG, SVG or WebP, required by Solana Pay wallets
+
+	// EVM networks payments are accepted on, Ethereum first
+	EVMNetworks []EVMNetwork
+
+	// Watch-only mode holds no private keys: deposit addresses are derived
+	// from xpubs or taken from the merchant's <CHAIN>_WATCH_ADDRESSES lists
+	WatchOnly      bool
+	WatchAddresses map[string][]string // keyed by chain
+
+	// Merchant receiving addresses: when set, every Solana or TON payment
+	// goes there with a unique reference instead of to a fresh address
+	SolanaMerchant string
+	TonMerchant    string
+
+	// Sweeping of deposit addresses, keyed by chain: the treasury address
+	// funds are moved to, and the hot wallet key paying gas for token sweeps
+	Treasuries    map[string]string
+	GasWalletKeys map[string]string
+
+	// Keys paying the gas of relayed EIP-3009 token transfers, keyed by
+	// chain; gasless payments are offered where one is set
+	RelayerKeys map[string]string
+
+	// Blocks a transaction must be buried under before a payment counts as paid
+	SolanaConfirmations  int
+	TonConfirmations     int
+	BitcoinConfirmations int
+	TronConfirmations    int
 }
 
 func Load() *Config {
@@ -19,18 +74,180 @@ func Load() *Config {
 		Environment:    getEnv("ENVIRONMENT", "development"),
 		Port:          getEnv("PORT", "8080"),
 		DatabaseURL:   getEnv("DATABASE_URL", "sqlite://./payments.db"),
-		EthereumRPC:   getEnv("ETHEREUM_RPC_URL", ""),
+		EthereumXPub:  getEnv("ETHEREUM_XPUB", ""),
 		SolanaRPC:     getEnv("SOLANA_RPC_URL", "https://api.mainnet-beta.solana.com"),
+		SolanaSeed:    getEnv("SOLANA_SEED", ""),
+		SolanaUSDC:    getEnv("SOLANA_USDC_MINT", "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"),
+		SolanaUSDT:    getEnv("SOLANA_USDT_MINT", "Es9vMFrzaCERmJfrF4H2FYD4KhHmo5nCZsPsCeSXQUXB"),
 		TonRPC:        getEnv("TON_RPC_URL", "https://toncenter.com/api/v2/jsonRPC"),
+		TonAPIKey:     getEnv("TON_API_KEY", ""),
+		TonUSDC:       getEnv("TON_USDC_MASTER", ""),
+		TonUSDT:       getEnv("TON_USDT_MASTER", "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs"),
+		BitcoinRPC:    getEnv("BITCOIN_ESPLORA_URL", "https://blockstream.info/api"),
+		BitcoinXPub:   getEnv("BITCOIN_XPUB", ""),
+		BitcoinNet:    getEnv("BITCOIN_NETWORK", "mainnet"),
+		TronAPI:       getEnv("TRON_API_URL", "https://api.trongrid.io"),
+		TronAPIKey:    getEnv("TRON_API_KEY", ""),
+		TronXPub:      getEnv("TRON_XPUB", ""),
+		TronUSDC:      getEnv("TRON_USDC_CONTRACT", ""),
+		TronUSDT:      getEnv("TRON_USDT_CONTRACT", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"),
+		LNDURL:        getEnv("LND_REST_URL", ""),
+		LNDMacaroon:   getEnv("LND_MACAROON", ""),
+		LNDCertPath:   getEnv("LND_TLS_CERT_PATH", ""),
 		PriceAPIKey:   getEnv("PRICE_API_KEY", ""),
+		KeystorePass:  getEnv("KEYSTORE_PASSPHRASE", ""),
 		WebhookSecret: getEnv("WEBHOOK_SECRET", "default-secret"),
 		WidgetBaseURL: getEnv("WIDGET_BASE_URL", "http://localhost:5173"),
+
+		MerchantName:    getEnv("MERCHANT_NAME", ""),
+		MerchantIconURL: getEnv("MERCHANT_ICON_URL", ""),
+
+		EVMNetworks: loadEVMNetworks(),
+
+		WatchOnly:      getEnvBool("WATCH_ONLY", false),
+		WatchAddresses: loadWatchAddresses(),
+
+		SolanaMerchant: getEnv("SOLANA_MERCHANT_ADDRESS", ""),
+		TonMerchant:    getEnv("TON_MERCHANT_ADDRESS", ""),
+
+		Treasuries:    loadChainSettings("_TREASURY_ADDRESS"),
+		GasWalletKeys: loadChainSettings("_GAS_WALLET_KEY"),
+
+		RelayerKeys: loadChainSettings("_RELAYER_KEY"),
+
+		SolanaConfirmations:  getEnvInt("SOLANA_CONFIRMATIONS", 32),
+		TonConfirmations:     getEnvInt("TON_CONFIRMATIONS", 1),
+		BitcoinConfirmations: getEnvInt("BITCOIN_CONFIRMATIONS", 2),
+		TronConfirmations:    getEnvInt("TRON_CONFIRMATIONS", 19),
 	}
 }
 
+// EVMNetwork configures one EVM-compatible network.
+type EVMNetwork struct {
+	Chain          string // payment option chain identifier, e.g. "polygon"
+	Name           string
+	ChainID        int64
+	RPCURL         string
+	NativeSymbol   string
+	NativePriceID  string // CoinGecko coin ID of the native asset
+	USDC           string
+	USDT           string
+	StableDecimals int
+	Confirmations  int
+	PollInterval   time.Duration
+
+	// CREATE2 forwarder factory deposit addresses are computed from, and
+	// the hash of the forwarder init code it deploys
+	ForwarderFactory      string
+	ForwarderInitCodeHash string
+}
+
+// evmNetworks holds the mainnet defaults of the supported EVM networks.
+// Each can be overridden through <PREFIX>_RPC_URL, <PREFIX>_USDC_CONTRACT,
+// <PREFIX>_USDT_CONTRACT and <PREFIX>_CONFIRMATIONS; forwarder deposit
+// addresses are enabled with <PREFIX>_FORWARDER_FACTORY and
+// <PREFIX>_FORWARDER_INIT_CODE_HASH.
+var evmNetworks = []struct {
+	prefix string
+	EVMNetwork
+}{
+	{"ETHEREUM", EVMNetwork{Chain: "ethereum", Name: "Ethereum", ChainID: 1, NativeSymbol: "ETH", NativePriceID: "ethereum",
+		USDC: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", USDT: "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		StableDecimals: 6, Confirmations: 12, PollInterval: 15 * time.Second}},
+	{"POLYGON", EVMNetwork{Chain: "polygon", Name: "Polygon", ChainID: 137, NativeSymbol: "POL", NativePriceID: "polygon-ecosystem-token",
+		USDC: "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359", USDT: "0xc2132D05D31c914a87C6611C10748AEb04B58e8F",
+		StableDecimals: 6, Confirmations: 64, PollInterval: 5 * time.Second}},
+	{"ARBITRUM", EVMNetwork{Chain: "arbitrum", Name: "Arbitrum", ChainID: 42161, NativeSymbol: "ETH", NativePriceID: "ethereum",
+		USDC: "0xaf88d065e77c8cC2239327C5EDb3A432268e5831", USDT: "0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9",
+		StableDecimals: 6, Confirmations: 20, PollInterval: 2 * time.Second}},
+	{"BASE", EVMNetwork{Chain: "base", Name: "Base", ChainID: 8453, NativeSymbol: "ETH", NativePriceID: "ethereum",
+		USDC: "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", StableDecimals: 6, Confirmations: 10, PollInterval: 4 * time.Second}},
+	{"BSC", EVMNetwork{Chain: "bsc", Name: "BNB Smart Chain", ChainID: 56, NativeSymbol: "BNB", NativePriceID: "binancecoin",
+		USDC: "0x8AC76a51cc950d9822D68b83fE1Ad97B32Cd580d", USDT: "0x55d398326f99059fF775485246999027B3197955",
+		StableDecimals: 18, Confirmations: 15, PollInterval: 3 * time.Second}},
+	{"OPTIMISM", EVMNetwork{Chain: "optimism", Name: "Optimism", ChainID: 10, NativeSymbol: "ETH", NativePriceID: "ethereum",
+		USDC: "0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85", USDT: "0x94b008aA00579c1307B0EF2c499aD98a8ce58e58",
+		StableDecimals: 6, Confirmations: 10, PollInterval: 4 * time.Second}},
+}
+
+// loadEVMNetworks returns Ethereum and every other EVM network that has an
+// RPC URL configured.
+func loadEVMNetworks() []EVMNetwork {
+	var networks []EVMNetwork
+	for i, def := range evmNetworks {
+		network := def.EVMNetwork
+		network.RPCURL = getEnv(def.prefix+"_RPC_URL", "")
+		if i > 0 && network.RPCURL == "" {
+			continue
+		}
+
+		network.USDC = getEnv(def.prefix+"_USDC_CONTRACT", network.USDC)
+		network.USDT = getEnv(def.prefix+"_USDT_CONTRACT", network.USDT)
+		network.Confirmations = getEnvInt(def.prefix+"_CONFIRMATIONS", network.Confirmations)
+		network.ForwarderFactory = getEnv(def.prefix+"_FORWARDER_FACTORY", "")
+		network.ForwarderInitCodeHash = getEnv(def.prefix+"_FORWARDER_INIT_CODE_HASH", "")
+		networks = append(networks, network)
+	}
+	return networks
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
+}
+
+// chainPrefixes maps the environment variable prefix of every chain that
+// hands out deposit addresses to its chain identifier.
+func chainPrefixes() map[string]string {
+	prefixes := map[string]string{
+		"SOLANA":  "solana",
+		"TON":     "ton",
+		"BITCOIN": "bitcoin",
+		"TRON":    "tron",
+	}
+	for _, def := range evmNetworks {
+		prefixes[def.prefix] = def.Chain
+	}
+	return prefixes
+}
+
+// loadWatchAddresses reads the comma-separated <CHAIN>_WATCH_ADDRESSES
+// lists.
+func loadWatchAddresses() map[string][]string {
+	lists := make(map[string][]string)
+	for prefix, chain := range chainPrefixes() {
+		for _, address := range strings.Split(getEnv(prefix+"_WATCH_ADDRESSES", ""), ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				lists[chain] = append(lists[chain], address)
+			}
+		}
+	}
+	return lists
+}
+
+// loadChainSettings reads <CHAIN><suffix> for every chain that has it set.
+func loadChainSettings(suffix string) map[string]string {
+	settings := make(map[string]string)
+	for prefix, chain := range chainPrefixes() {
+		if value := getEnv(prefix+suffix, ""); value != "" {
+			settings[chain] = value
+		}
+	}
+	return settings
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
 }
\ No newline at end of file
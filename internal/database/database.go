@@ -30,6 +30,13 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 		&models.Payment{},
 		&models.PaymentOption{},
 		&models.Transaction{},
+		&models.Token{},
+		&models.DerivationCounter{},
+		&models.NonceCounter{},
+		&models.ChainCursor{},
+		&models.EncryptedKey{},
+		&models.KeystoreParams{},
+		&models.OutgoingTransaction{},
 	)
 	if err != nil {
 		return nil, err
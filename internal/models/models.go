@@ -11,6 +11,7 @@ type PaymentStatus string
 
 const (
 	StatusPending   PaymentStatus = "pending"
+	StatusDetected  PaymentStatus = "detected"
 	StatusPaid      PaymentStatus = "paid"
 	StatusExpired   PaymentStatus = "expired"
 	StatusCancelled PaymentStatus = "cancelled"
@@ -22,6 +23,18 @@ const (
 	ChainEthereum Chain = "ethereum"
 	ChainSolana   Chain = "solana"
 	ChainTON      Chain = "ton"
+	ChainBitcoin  Chain = "bitcoin"
+	ChainTron     Chain = "tron"
+
+	// Lightning payments are made to BOLT11 invoices rather than addresses
+	ChainLightning Chain = "lightning"
+
+	// EVM networks besides Ethereum
+	ChainPolygon  Chain = "polygon"
+	ChainArbitrum Chain = "arbitrum"
+	ChainBase     Chain = "base"
+	ChainBSC      Chain = "bsc"
+	ChainOptimism Chain = "optimism"
 )
 
 type TokenType string
@@ -32,9 +45,27 @@ const (
 	TokenUSDT   TokenType = "usdt"
 )
 
+// PriceSourceUSD marks tokens pegged 1:1 to the US dollar.
+const PriceSourceUSD = "usd"
+
+// Token is an asset payments can be made in. Rows are seeded from the
+// configured defaults at startup and can then be edited in the database.
+type Token struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Chain       Chain     `json:"chain" gorm:"uniqueIndex:idx_tokens_chain_token"`
+	Token       TokenType `json:"token" gorm:"uniqueIndex:idx_tokens_chain_token"`
+	Symbol      string    `json:"symbol"`
+	Decimals    int       `json:"decimals"`
+	Contract    string    `json:"contract"`     // ERC-20 contract, SPL mint or jetton master; empty for native assets
+	PriceSource string    `json:"price_source"` // CoinGecko coin ID, or PriceSourceUSD
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 type Payment struct {
 	ID          string          `json:"id" gorm:"primaryKey"`
-	Amount      decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Amount      decimal.Decimal `json:"amount" gorm:"type:text"`
 	Currency    string          `json:"currency"`
 	Status      PaymentStatus   `json:"status"`
 	WebhookURL  string          `json:"webhook_url"`
@@ -55,10 +86,75 @@ type PaymentOption struct {
 	Chain     Chain           `json:"chain"`
 	Token     TokenType       `json:"token"`
 	Address   string          `json:"address"`
-	Amount    decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Amount    decimal.Decimal `json:"amount" gorm:"type:text"` // text, as SQLite keeps decimal columns as floats
 	Symbol    string          `json:"symbol"`
 	Decimals  int             `json:"decimals"`
 	CreatedAt time.Time       `json:"created_at"`
+
+	// Reference identifies an invoice-based option's invoice, e.g. the
+	// Lightning payment hash, or the payment itself, e.g. a TON comment on
+	// a shared merchant address or the Solana Pay reference key every
+	// Solana option gets.
+	Reference string `json:"reference,omitempty"`
+
+	// PaymentURI is a payment request for wallets, e.g. a Solana Pay
+	// transfer request, meant to be opened as a link or scanned as a QR code.
+	PaymentURI string `json:"payment_uri,omitempty"`
+
+	// DerivationPath is empty for randomly generated addresses.
+	DerivationIndex uint32 `json:"-"`
+	DerivationPath  string `json:"-"`
+
+	// ForwarderSalt is set for CREATE2 forwarder addresses.
+	ForwarderSalt string `json:"-"`
+}
+
+// ChainCursor records the last block a chain scanner has processed.
+type ChainCursor struct {
+	Chain       Chain `gorm:"primaryKey"`
+	BlockNumber uint64
+	BlockHash   string
+	UpdatedAt   time.Time
+}
+
+// NonceCounter holds the next unused transaction nonce of an account the
+// gateway sends from.
+type NonceCounter struct {
+	Chain     Chain  `gorm:"primaryKey"`
+	Address   string `gorm:"primaryKey"`
+	NextNonce uint64
+	UpdatedAt time.Time
+}
+
+// DerivationCounter holds the next unused HD derivation index for a
+// derivation scope, usually a chain.
+type DerivationCounter struct {
+	Scope     string `gorm:"primaryKey"`
+	NextIndex uint32
+	UpdatedAt time.Time
+}
+
+// EncryptedKey is the private key of a randomly generated deposit address,
+// sealed with the keystore's master key.
+type EncryptedKey struct {
+	Address    string `gorm:"primaryKey"`
+	Chain      Chain
+	Nonce      []byte
+	Ciphertext []byte
+	CreatedAt  time.Time
+}
+
+// KeystoreParams holds the scrypt parameters and salt the keystore's master
+// key is derived from the passphrase with, and a sealed check value that
+// tells a wrong passphrase apart.
+type KeystoreParams struct {
+	ID        uint `gorm:"primaryKey"`
+	Salt      []byte
+	ScryptN   int
+	ScryptR   int
+	ScryptP   int
+	Check     []byte
+	CreatedAt time.Time
 }
 
 type Transaction struct {
@@ -68,10 +164,63 @@ type Transaction struct {
 	TxHash        string          `json:"tx_hash" gorm:"uniqueIndex"`
 	FromAddress   string          `json:"from_address"`
 	ToAddress     string          `json:"to_address"`
-	Amount        decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:text"`
 	Token         TokenType       `json:"token"`
+	TokenContract string          `json:"token_contract,omitempty"` // ERC-20 contract, SPL mint or jetton master
+	TokenAccount  string          `json:"token_account,omitempty"`  // SPL token account or jetton wallet credited
+	BlockNumber   uint64          `json:"block_number"`
+	BlockHash     string          `json:"block_hash"`
 	Confirmations int             `json:"confirmations"`
 	Confirmed     bool            `json:"confirmed"`
+	Swept         bool            `json:"-" gorm:"default:false"` // funds moved on to the treasury
+	SweepID       *uint           `json:"-" gorm:"index"`         // batched sweep moving the funds, if any
 	CreatedAt     time.Time       `json:"created_at"`
 	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+type OutgoingKind string
+
+const (
+	OutgoingSweep    OutgoingKind = "sweep"
+	OutgoingGasTopUp OutgoingKind = "gas_topup"
+	OutgoingFlush    OutgoingKind = "flush" // batched sweep of forwarder contracts
+)
+
+type OutgoingStatus string
+
+const (
+	OutgoingPending   OutgoingStatus = "pending" // not broadcast yet
+	OutgoingSubmitted OutgoingStatus = "submitted"
+	OutgoingConfirmed OutgoingStatus = "confirmed"
+	OutgoingFailed    OutgoingStatus = "failed"
+)
+
+// OutgoingTransaction is a transfer sent by the gateway, such as a sweep of
+// a deposit address to the treasury or the gas top-up preceding it.
+type OutgoingTransaction struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	Kind          OutgoingKind    `json:"kind"`
+	Chain         Chain           `json:"chain"`
+	PaymentID     string          `json:"payment_id" gorm:"index"`
+	TransactionID uint            `json:"transaction_id" gorm:"index"` // incoming transaction the transfer follows up on
+	FromAddress   string          `json:"from_address"`
+	ToAddress     string          `json:"to_address"`
+	Token         TokenType       `json:"token"`
+	TokenContract string          `json:"token_contract,omitempty"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:text"`
+	TxHash        string          `json:"tx_hash" gorm:"index"`
+	Status        OutgoingStatus  `json:"status"`
+	Error         string          `json:"error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// State of senders that replace stuck transactions, e.g. on EVM
+	// networks: the last signed transaction, its fees in wei, when it was
+	// broadcast, and the hashes it replaced, comma-separated.
+	Nonce          *uint64    `json:"nonce,omitempty"`
+	GasTipCap      string     `json:"-"`
+	GasFeeCap      string     `json:"-"`
+	RawTx          string     `json:"-" gorm:"type:text"`
+	SubmittedAt    *time.Time `json:"-"`
+	ReplacedHashes string     `json:"-" gorm:"type:text"`
 }
\ No newline at end of file
@@ -2,204 +2,330 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/chains/bitcoin"
+	"multi-chain-payment-gateway/internal/chains/evm"
+	"multi-chain-payment-gateway/internal/chains/lightning"
+	"multi-chain-payment-gateway/internal/chains/solana"
+	"multi-chain-payment-gateway/internal/chains/ton"
+	"multi-chain-payment-gateway/internal/chains/tron"
 	"multi-chain-payment-gateway/internal/config"
 	"multi-chain-payment-gateway/internal/models"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type BlockchainService struct {
-	config    *config.Config
-	ethClient *ethclient.Client
-	wallets   map[string]*WalletInfo
-}
+	config   *config.Config
+	registry *chains.Registry
+	tokens   *TokenRegistry
+	keys     KeyStore
 
-type WalletInfo struct {
-	Address    string
-	PrivateKey string
-	Chain      models.Chain
+	// watchOnly is set when the gateway must not hold private keys
+	watchOnly bool
+	pool      *addressPool
 }
 
-func NewBlockchainService(cfg *config.Config) *BlockchainService {
-	s := &BlockchainService{
-		config:  cfg,
-		wallets: make(map[string]*WalletInfo),
+func NewBlockchainService(cfg *config.Config, db *gorm.DB, tokens *TokenRegistry, keys KeyStore) (*BlockchainService, error) {
+	registry, err := newChainRegistry(cfg, NewChainStore(db), tokens)
+	if err != nil {
+		return nil, err
 	}
-
-	// Initialize Ethereum client if RPC URL is provided
-	if cfg.EthereumRPC != "" {
-		if client, err := ethclient.Dial(cfg.EthereumRPC); err == nil {
-			s.ethClient = client
+	if cfg.WatchOnly {
+		if registry, err = watchOnlyRegistry(registry, cfg); err != nil {
+			return nil, err
 		}
 	}
+	if err := checkRelayerKeys(registry, cfg.RelayerKeys); err != nil {
+		return nil, err
+	}
+
+	return &BlockchainService{
+		config:   cfg,
+		registry: registry,
+		tokens:   tokens,
+		keys:     keys,
 
-	return s
+		watchOnly: cfg.WatchOnly,
+		pool:      newAddressPool(db, cfg.WatchAddresses),
+	}, nil
 }
 
-func (s *BlockchainService) GenerateWallet(chain models.Chain) (*WalletInfo, error) {
-	switch chain {
-	case models.ChainEthereum:
-		return s.generateEthereumWallet()
-	case models.ChainSolana:
-		return s.generateSolanaWallet()
-	case models.ChainTON:
-		return s.generateTONWallet()
-	default:
-		return nil, fmt.Errorf("unsupported chain: %s", chain)
+// newChainRegistry builds the adapters of all supported chains, each
+// watching the tokens enabled for it in the token registry.
+func newChainRegistry(cfg *config.Config, store chains.Store, tokens *TokenRegistry) (*chains.Registry, error) {
+	registry := chains.NewRegistry()
+
+	for _, network := range cfg.EVMNetworks {
+		chain := models.Chain(network.Chain)
+		adapter, err := evm.New(evm.Config{
+			Chain:         chain,
+			Name:          network.Name,
+			ChainID:       network.ChainID,
+			RPCURL:        network.RPCURL,
+			XPub:          cfg.EthereumXPub,
+			Tokens:        tokens.ChainTokens(chain),
+			Confirmations: network.Confirmations,
+			PollInterval:  network.PollInterval,
+
+			ForwarderFactory:      network.ForwarderFactory,
+			ForwarderInitCodeHash: network.ForwarderInitCodeHash,
+		}, store)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(adapter)
 	}
-}
 
-func (s *BlockchainService) generateEthereumWallet() (*WalletInfo, error) {
-	privateKey, err := crypto.GenerateKey()
+	sol, err := solana.New(solana.Config{
+		RPCURL:        cfg.SolanaRPC,
+		Seed:          cfg.SolanaSeed,
+		Tokens:        tokens.ChainTokens(models.ChainSolana),
+		Confirmations: cfg.SolanaConfirmations,
+
+		MerchantAddress: cfg.SolanaMerchant,
+	}, store)
 	if err != nil {
 		return nil, err
 	}
+	registry.Register(sol)
 
-	address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
-	privateKeyHex := fmt.Sprintf("%x", privateKey.D.Bytes())
+	tonAdapter, err := ton.New(ton.Config{
+		RPCURL:        cfg.TonRPC,
+		APIKey:        cfg.TonAPIKey,
+		Tokens:        tokens.ChainTokens(models.ChainTON),
+		Confirmations: cfg.TonConfirmations,
 
-	wallet := &WalletInfo{
-		Address:    address,
-		PrivateKey: privateKeyHex,
-		Chain:      models.ChainEthereum,
+		MerchantAddress: cfg.TonMerchant,
+	}, store)
+	if err != nil {
+		return nil, err
 	}
+	registry.Register(tonAdapter)
 
-	s.wallets[address] = wallet
-	return wallet, nil
+	btc, err := bitcoin.New(bitcoin.Config{
+		EsploraURL:    cfg.BitcoinRPC,
+		XPub:          cfg.BitcoinXPub,
+		Network:       cfg.BitcoinNet,
+		Tokens:        tokens.ChainTokens(models.ChainBitcoin),
+		Confirmations: cfg.BitcoinConfirmations,
+	}, store)
+	if err != nil {
+		return nil, err
+	}
+	registry.Register(btc)
+
+	trx, err := tron.New(tron.Config{
+		APIURL:        cfg.TronAPI,
+		APIKey:        cfg.TronAPIKey,
+		XPub:          cfg.TronXPub,
+		Tokens:        tokens.ChainTokens(models.ChainTron),
+		Confirmations: cfg.TronConfirmations,
+	}, store)
+	if err != nil {
+		return nil, err
+	}
+	registry.Register(trx)
+
+	if cfg.LNDURL != "" {
+		ln, err := lightning.New(lightning.Config{
+			RESTURL:     cfg.LNDURL,
+			Macaroon:    cfg.LNDMacaroon,
+			TLSCertPath: cfg.LNDCertPath,
+			Tokens:      tokens.ChainTokens(models.ChainLightning),
+		}, store)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(ln)
+	}
+
+	return registry, nil
 }
 
-func (s *BlockchainService) generateSolanaWallet() (*WalletInfo, error) {
-	bytes := make([]byte, 32)
-	_, err := rand.Read(bytes)
+// WatchOnly reports whether the gateway runs without private keys.
+func (s *BlockchainService) WatchOnly() bool {
+	return s.watchOnly
+}
+
+// RequireCustody returns ErrWatchOnly in watch-only mode. Features that
+// move funds, such as sweeps and refunds, must check it before running.
+func (s *BlockchainService) RequireCustody() error {
+	if s.watchOnly {
+		return ErrWatchOnly
+	}
+	return nil
+}
+
+// Registry exposes the chain adapters the service dispatches to.
+func (s *BlockchainService) Registry() *chains.Registry {
+	return s.registry
+}
+
+// Tokens exposes the token registry the adapters were configured from.
+func (s *BlockchainService) Tokens() *TokenRegistry {
+	return s.tokens
+}
+
+func (s *BlockchainService) GenerateWallet(option *models.PaymentOption) (*chains.Wallet, error) {
+	chain := option.Chain
+	adapter, err := s.registry.Get(chain)
 	if err != nil {
 		return nil, err
 	}
 
-	address := fmt.Sprintf("Sol%x", bytes[:16])
-	privateKey := fmt.Sprintf("%x", bytes)
+	if s.watchOnly && s.pool.has(chain) {
+		address, err := s.pool.take(chain)
+		if err != nil {
+			return nil, fmt.Errorf("no %s deposit address available: %w", chain, err)
+		}
+		return &chains.Wallet{Address: address, Chain: chain}, nil
+	}
+
+	wallet, err := adapter.NewAddress(chains.AddressRequest{PaymentID: option.PaymentID})
+	if err != nil {
+		return nil, err
+	}
 
-	wallet := &WalletInfo{
-		Address:    address,
-		PrivateKey: privateKey,
-		Chain:      models.ChainSolana,
+	if s.watchOnly && wallet.PrivateKey != "" {
+		return nil, fmt.Errorf("%s address generation: %w", chain, ErrWatchOnly)
 	}
 
-	s.wallets[address] = wallet
+	// Keys of random addresses only ever exist in the keystore
+	if wallet.PrivateKey != "" {
+		if err := s.keys.Store(chain, wallet.Address, wallet.PrivateKey); err != nil {
+			return nil, fmt.Errorf("failed to store key of %s address: %w", chain, err)
+		}
+		wallet.PrivateKey = ""
+	}
 	return wallet, nil
 }
 
-func (s *BlockchainService) generateTONWallet() (*WalletInfo, error) {
-	bytes := make([]byte, 32)
-	_, err := rand.Read(bytes)
+// IssuesInvoices reports whether chain bills payments with invoices
+// instead of deposit addresses.
+func (s *BlockchainService) IssuesInvoices(chain models.Chain) bool {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return false
+	}
+	_, ok := adapter.(chains.InvoiceIssuer)
+	return ok
+}
+
+// CreateInvoice issues the invoice an option on an invoice-based chain is
+// paid to, expiring at expiresAt.
+func (s *BlockchainService) CreateInvoice(option *models.PaymentOption, expiresAt time.Time) (*chains.Invoice, error) {
+	adapter, err := s.registry.Get(option.Chain)
 	if err != nil {
 		return nil, err
 	}
+	issuer, ok := adapter.(chains.InvoiceIssuer)
+	if !ok {
+		return nil, fmt.Errorf("%s does not issue invoices", option.Chain)
+	}
+
+	token, ok := chains.FindToken(adapter, option.Token)
+	if !ok {
+		return nil, fmt.Errorf("unsupported token %s on %s", option.Token, option.Chain)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return issuer.NewInvoice(ctx, chains.InvoiceRequest{
+		PaymentID: option.PaymentID,
+		Token:     token,
+		Amount:    option.Amount,
+		ExpiresAt: expiresAt,
+	})
+}
 
-	address := fmt.Sprintf("TON%x", bytes[:16])
-	privateKey := fmt.Sprintf("%x", bytes)
+// ValidateAddress reports whether address is well-formed for chain.
+func (s *BlockchainService) ValidateAddress(chain models.Chain, address string) error {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return err
+	}
+	return adapter.ValidateAddress(address)
+}
 
-	wallet := &WalletInfo{
-		Address:    address,
-		PrivateKey: privateKey,
-		Chain:      models.ChainTON,
+// Sync lets a chain catch up on the deposits currently awaited on it, so
+// that block-scanning adapters have recorded transfers before
+// CheckTransaction.
+func (s *BlockchainService) Sync(chain models.Chain, deposits []chains.Deposit) error {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return err
 	}
 
-	s.wallets[address] = wallet
-	return wallet, nil
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return adapter.Sync(ctx, deposits)
 }
 
-func (s *BlockchainService) CheckTransaction(chain models.Chain, address string, amount decimal.Decimal) (*models.Transaction, error) {
-	switch chain {
-	case models.ChainEthereum:
-		return s.checkEthereumTransaction(address, amount)
-	case models.ChainSolana:
-		return s.checkSolanaTransaction(address, amount)
-	case models.ChainTON:
-		return s.checkTONTransaction(address, amount)
-	default:
-		return nil, fmt.Errorf("unsupported chain: %s", chain)
+// PollInterval is how often chain should be checked for payments.
+func (s *BlockchainService) PollInterval(chain models.Chain, fallback time.Duration) time.Duration {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return fallback
+	}
+	if poller, ok := adapter.(chains.Poller); ok && poller.PollInterval() > 0 {
+		return poller.PollInterval()
 	}
+	return fallback
 }
 
-func (s *BlockchainService) checkEthereumTransaction(address string, expectedAmount decimal.Decimal) (*models.Transaction, error) {
-	if s.ethClient != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+// Deposit describes the transfer expected for a payment option.
+func (s *BlockchainService) Deposit(option models.PaymentOption) (chains.Deposit, error) {
+	adapter, err := s.registry.Get(option.Chain)
+	if err != nil {
+		return chains.Deposit{}, err
+	}
 
-		_, err := s.ethClient.BalanceAt(ctx, common.HexToAddress(address), nil)
-		if err != nil {
-			return nil, err
-		}
+	token, ok := chains.FindToken(adapter, option.Token)
+	if !ok {
+		return chains.Deposit{}, fmt.Errorf("unsupported token %s on %s", option.Token, option.Chain)
 	}
 
-	return nil, nil
+	return chains.Deposit{
+		Address:   option.Address,
+		Token:     token,
+		Amount:    option.Amount,
+		Reference: option.Reference,
+	}, nil
 }
 
-func (s *BlockchainService) checkSolanaTransaction(address string, expectedAmount decimal.Decimal) (*models.Transaction, error) {
-	return nil, nil
+func (s *BlockchainService) CheckTransaction(chain models.Chain, deposit chains.Deposit) (*models.Transaction, error) {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return adapter.CheckPayment(ctx, deposit)
 }
 
-func (s *BlockchainService) checkTONTransaction(address string, expectedAmount decimal.Decimal) (*models.Transaction, error) {
-	return nil, nil
+// GetConfirmations reports how deep a detected transaction is buried. It
+// returns chains.ErrTransactionDropped if the transaction has left the chain.
+func (s *BlockchainService) GetConfirmations(tx *models.Transaction) (int, error) {
+	adapter, err := s.registry.Get(tx.Chain)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return adapter.Confirmations(ctx, tx)
 }
 
-func (s *BlockchainService) GetTokenDecimals(chain models.Chain, token models.TokenType) int {
-	switch chain {
-	case models.ChainEthereum:
-		if token == models.TokenNative {
-			return 18
-		}
-		return 6
-	case models.ChainSolana:
-		if token == models.TokenNative {
-			return 9
-		}
-		return 6
-	case models.ChainTON:
-		if token == models.TokenNative {
-			return 9
-		}
-		return 6
-	default:
-		return 18
-	}
-}
-
-func (s *BlockchainService) GetTokenSymbol(chain models.Chain, token models.TokenType) string {
-	switch chain {
-	case models.ChainEthereum:
-		switch token {
-		case models.TokenNative:
-			return "ETH"
-		case models.TokenUSDC:
-			return "USDC"
-		case models.TokenUSDT:
-			return "USDT"
-		}
-	case models.ChainSolana:
-		switch token {
-		case models.TokenNative:
-			return "SOL"
-		case models.TokenUSDC:
-			return "USDC"
-		case models.TokenUSDT:
-			return "USDT"
-		}
-	case models.ChainTON:
-		switch token {
-		case models.TokenNative:
-			return "TON"
-		case models.TokenUSDC:
-			return "USDC"
-		case models.TokenUSDT:
-			return "USDT"
-		}
+// RequiredConfirmations is the depth at which a chain's transactions are
+// treated as final.
+func (s *BlockchainService) RequiredConfirmations(chain models.Chain) int {
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return 1
 	}
-	return ""
+	return adapter.RequiredConfirmations()
 }
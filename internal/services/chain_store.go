@@ -0,0 +1,72 @@
+package services
+
+import (
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ChainStore persists chain adapter state in the database.
+type ChainStore struct {
+	db *gorm.DB
+}
+
+func NewChainStore(db *gorm.DB) *ChainStore {
+	return &ChainStore{db: db}
+}
+
+func (s *ChainStore) NextIndex(scope string) (uint32, error) {
+	var index uint32
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		counter := models.DerivationCounter{Scope: scope}
+		if err := tx.FirstOrCreate(&counter, models.DerivationCounter{Scope: scope}).Error; err != nil {
+			return err
+		}
+		index = counter.NextIndex
+		return tx.Model(&counter).Update("next_index", counter.NextIndex+1).Error
+	})
+	return index, err
+}
+
+func (s *ChainStore) LoadCursor(chain models.Chain) (*chains.Cursor, error) {
+	var cursor models.ChainCursor
+	err := s.db.First(&cursor, "chain = ?", chain).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &chains.Cursor{Height: cursor.BlockNumber, Hash: cursor.BlockHash}, nil
+}
+
+func (s *ChainStore) SaveCursor(chain models.Chain, cursor chains.Cursor) error {
+	return s.db.Save(&models.ChainCursor{
+		Chain:       chain,
+		BlockNumber: cursor.Height,
+		BlockHash:   cursor.Hash,
+	}).Error
+}
+
+func (s *ChainStore) ReserveNonce(chain models.Chain, address string, minimum uint64) (uint64, error) {
+	var nonce uint64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		counter := models.NonceCounter{Chain: chain, Address: address}
+		if err := tx.FirstOrCreate(&counter, models.NonceCounter{Chain: chain, Address: address}).Error; err != nil {
+			return err
+		}
+		nonce = counter.NextNonce
+		if nonce < minimum {
+			nonce = minimum
+		}
+		return tx.Model(&counter).Update("next_nonce", nonce+1).Error
+	})
+	return nonce, err
+}
+
+func (s *ChainStore) ReleaseNonce(chain models.Chain, address string, nonce uint64) error {
+	return s.db.Model(&models.NonceCounter{}).
+		Where("chain = ? AND address = ? AND next_nonce = ?", chain, address, nonce+1).
+		Update("next_nonce", nonce).Error
+}
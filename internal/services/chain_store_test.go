@@ -0,0 +1,103 @@
+package services
+
+import (
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/database"
+	"multi-chain-payment-gateway/internal/models"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testDB returns a fresh, migrated database.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Initialize("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestNextIndex(t *testing.T) {
+	store := NewChainStore(testDB(t))
+
+	tests := []struct {
+		scope string
+		want  uint32
+	}{
+		{"ethereum", 0},
+		{"ethereum", 1},
+		{"bitcoin", 0},
+		{"ethereum", 2},
+	}
+	for _, tt := range tests {
+		got, err := store.NextIndex(tt.scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("NextIndex(%s) = %d, want %d", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	store := NewChainStore(testDB(t))
+
+	if cursor, err := store.LoadCursor(models.ChainEthereum); err != nil || cursor != nil {
+		t.Fatalf("LoadCursor() = %v, %v, want none", cursor, err)
+	}
+	for _, saved := range []chains.Cursor{{Height: 10, Hash: "0xa"}, {Height: 12}} {
+		if err := store.SaveCursor(models.ChainEthereum, saved); err != nil {
+			t.Fatal(err)
+		}
+		cursor, err := store.LoadCursor(models.ChainEthereum)
+		if err != nil || cursor == nil || *cursor != saved {
+			t.Errorf("LoadCursor() = %v, %v, want %v", cursor, err, saved)
+		}
+	}
+	if cursor, _ := store.LoadCursor(models.ChainBase); cursor != nil {
+		t.Errorf("cursor of another chain = %v", cursor)
+	}
+}
+
+func TestReserveNonce(t *testing.T) {
+	store := NewChainStore(testDB(t))
+	const address = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+
+	steps := []struct {
+		name    string
+		release uint64 // nonce released first, if not 0
+		minimum uint64
+		want    uint64
+	}{
+		{name: "from the pending nonce", minimum: 5, want: 5},
+		{name: "after a reservation", minimum: 5, want: 6},
+		{name: "pending nonce moved on", minimum: 9, want: 9},
+		{name: "released last nonce", release: 9, want: 9},
+	}
+	for _, step := range steps {
+		if step.release != 0 {
+			if err := store.ReleaseNonce(models.ChainEthereum, address, step.release); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := store.ReserveNonce(models.ChainEthereum, address, step.minimum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want {
+			t.Errorf("%s: ReserveNonce() = %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	// A nonce is only released while no later one is reserved
+	if err := store.ReleaseNonce(models.ChainEthereum, address, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.ReserveNonce(models.ChainEthereum, address, 0); got != 10 {
+		t.Errorf("ReserveNonce() = %d after releasing an earlier nonce, want 10", got)
+	}
+}
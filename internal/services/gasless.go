@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/shopspring/decimal"
+)
+
+// ErrGaslessUnavailable is returned for payment options that cannot be
+// paid with a relayed transfer authorization.
+var ErrGaslessUnavailable = errors.New("gasless payment is not available for this option")
+
+// GaslessPayment is a payer's signed transfer authorization for a payment
+// option, over the typed data returned by GaslessTypedData.
+type GaslessPayment struct {
+	From      string `json:"from" binding:"required"`
+	Nonce     string `json:"nonce" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// checkRelayerKeys makes sure every configured relayer key belongs to a
+// chain that can relay authorizations.
+func checkRelayerKeys(registry *chains.Registry, keys map[string]string) error {
+	for name, key := range keys {
+		chain := models.Chain(name)
+		adapter, err := registry.Get(chain)
+		if err != nil {
+			return err
+		}
+		_, relays := adapter.(chains.AuthorizationRelayer)
+		sender, sends := adapter.(chains.Sender)
+		if !relays || !sends {
+			return fmt.Errorf("%s does not support relaying transfer authorizations", chain)
+		}
+		address, err := sender.AddressOf(key)
+		if err != nil {
+			return fmt.Errorf("invalid %s relayer key: %w", chain, err)
+		}
+		log.Printf("Relaying gasless %s payments from %s", chain, address)
+	}
+	return nil
+}
+
+// relayer returns the adapter relaying authorizations on chain and the key
+// paying for them.
+func (s *BlockchainService) relayer(chain models.Chain) (chains.ChainAdapter, string, error) {
+	key := s.config.RelayerKeys[string(chain)]
+	if key == "" {
+		return nil, "", ErrGaslessUnavailable
+	}
+	adapter, err := s.registry.Get(chain)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := adapter.(chains.AuthorizationRelayer); !ok {
+		return nil, "", ErrGaslessUnavailable
+	}
+	return adapter, key, nil
+}
+
+// transferAuthorization describes the transfer that pays option in full
+// before the payment expires.
+func (s *BlockchainService) transferAuthorization(payment *models.Payment, option *models.PaymentOption, from, nonce string) (chains.ChainAdapter, string, chains.TransferAuthorization, error) {
+	adapter, key, err := s.relayer(option.Chain)
+	if err != nil {
+		return nil, "", chains.TransferAuthorization{}, err
+	}
+	token, ok := chains.FindToken(adapter, option.Token)
+	if !ok || token.Token == models.TokenNative {
+		return nil, "", chains.TransferAuthorization{}, ErrGaslessUnavailable
+	}
+
+	return adapter, key, chains.TransferAuthorization{
+		Token:       token,
+		From:        from,
+		To:          option.Address,
+		Value:       option.Amount.Shift(int32(token.Decimals)).Ceil().BigInt(),
+		ValidBefore: payment.ExpiresAt,
+		Nonce:       nonce,
+	}, nil
+}
+
+// GaslessTypedData returns the typed data a payer signs to pay an option
+// from the address from without holding gas, with a fresh nonce.
+func (s *PaymentService) GaslessTypedData(paymentID string, optionID uint, from string) (interface{}, error) {
+	payment, option, err := s.payableOption(paymentID, optionID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	adapter, _, auth, err := s.blockchainService.transferAuthorization(payment, option, from, hexutil.Encode(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return adapter.(chains.AuthorizationRelayer).AuthorizationTypedData(ctx, auth)
+}
+
+// SubmitGasless relays a signed authorization paying an option and records
+// its transaction, which is then tracked like any detected payment. The
+// payment is claimed first, so an option cannot be paid twice.
+func (s *PaymentService) SubmitGasless(paymentID string, optionID uint, req GaslessPayment) (*models.Transaction, error) {
+	payment, option, err := s.payableOption(paymentID, optionID)
+	if err != nil {
+		return nil, err
+	}
+	adapter, key, auth, err := s.blockchainService.transferAuthorization(payment, option, req.From, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Claim the payment before relaying, so a concurrent detection or a
+	// second submission cannot pay it twice. The relay itself runs outside
+	// of any database transaction, which would hold the database lock for
+	// as long as the node takes to answer.
+	result := s.db.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, models.StatusPending).
+		Update("status", models.StatusDetected)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPaymentNotPending
+	}
+
+	hash, err := adapter.(chains.AuthorizationRelayer).RelayAuthorization(ctx, key, auth, req.Signature)
+	if err != nil {
+		s.releaseClaim(payment)
+		return nil, err
+	}
+
+	tx := &models.Transaction{
+		PaymentID:     payment.ID,
+		Chain:         option.Chain,
+		TxHash:        hash,
+		FromAddress:   auth.From,
+		ToAddress:     option.Address,
+		Amount:        decimal.NewFromBigInt(auth.Value, -int32(auth.Token.Decimals)),
+		Token:         option.Token,
+		TokenContract: auth.Token.Contract,
+	}
+	if err := s.db.Create(tx).Error; err != nil {
+		// The transfer was relayed, so the chain scanner picks it up once
+		// the payment is pending again
+		log.Printf("Error recording relayed transaction %s for payment %s: %v", hash, payment.ID, err)
+		s.releaseClaim(payment)
+		return nil, err
+	}
+
+	payment.Status = models.StatusDetected
+	log.Printf("Payment %s detected with relayed transaction %s", payment.ID, tx.TxHash)
+	s.notify(payment, EventPaymentDetected)
+	return tx, nil
+}
+
+// releaseClaim puts a payment claimed for a relayed transfer back to
+// pending.
+func (s *PaymentService) releaseClaim(payment *models.Payment) {
+	err := s.db.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, models.StatusDetected).
+		Update("status", models.StatusPending).Error
+	if err != nil {
+		log.Printf("Error releasing payment %s: %v", payment.ID, err)
+	}
+}
+
+// payableOption loads an option of a payment that is still awaiting
+// payment.
+func (s *PaymentService) payableOption(paymentID string, optionID uint) (*models.Payment, *models.PaymentOption, error) {
+	var payment models.Payment
+	if err := s.db.First(&payment, "id = ?", paymentID).Error; err != nil {
+		return nil, nil, err
+	}
+	if payment.Status != models.StatusPending || !payment.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrPaymentNotPending
+	}
+
+	var option models.PaymentOption
+	if err := s.db.First(&option, "id = ? AND payment_id = ?", optionID, paymentID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &payment, &option, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"gorm.io/gorm"
+)
+
+// scrypt parameters of new keystores, the same as go-ethereum's standard
+// keystore. They only apply when a keystore is first created; existing
+// ones keep the parameters they were created with.
+const (
+	scryptN = 1 << 18
+	scryptR = 8
+	scryptP = 1
+)
+
+// keystoreCheck is sealed with the master key when a keystore is created,
+// so Unlock can tell a wrong passphrase apart.
+var keystoreCheck = []byte("multi-chain-payment-gateway keystore")
+
+var (
+	ErrLocked          = errors.New("keystore is locked")
+	ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+	ErrKeyNotFound     = errors.New("no key stored for address")
+)
+
+// KeyStore keeps the private keys of generated deposit addresses encrypted
+// at rest. Keys can only be stored or read while the keystore is unlocked.
+type KeyStore interface {
+	Unlock(passphrase string) error
+	Lock()
+	Store(chain models.Chain, address, privateKey string) error
+	PrivateKey(address string) (string, error)
+}
+
+// DBKeyStore is a KeyStore in the database. A master key is derived from
+// the passphrase with scrypt on Unlock, and every private key is sealed
+// with AES-256-GCM under it, bound to its address.
+type DBKeyStore struct {
+	db *gorm.DB
+
+	mu   sync.RWMutex
+	aead cipher.AEAD // nil while locked
+}
+
+func NewKeyStore(db *gorm.DB) *DBKeyStore {
+	return &DBKeyStore{db: db}
+}
+
+// Unlock derives the master key from passphrase. The first unlock of an
+// empty database creates the keystore with that passphrase.
+func (s *DBKeyStore) Unlock(passphrase string) error {
+	var params models.KeystoreParams
+	err := s.db.First(&params).Error
+	if err == gorm.ErrRecordNotFound {
+		return s.create(passphrase)
+	}
+	if err != nil {
+		return err
+	}
+
+	aead, err := deriveAEAD(passphrase, params)
+	if err != nil {
+		return err
+	}
+	if len(params.Check) < aead.NonceSize() {
+		return errors.New("corrupt keystore check value")
+	}
+	nonce, sealed := params.Check[:aead.NonceSize()], params.Check[aead.NonceSize():]
+	if _, err := aead.Open(nil, nonce, sealed, nil); err != nil {
+		return ErrWrongPassphrase
+	}
+
+	s.mu.Lock()
+	s.aead = aead
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *DBKeyStore) create(passphrase string) error {
+	params := models.KeystoreParams{
+		Salt:    make([]byte, 32),
+		ScryptN: scryptN,
+		ScryptR: scryptR,
+		ScryptP: scryptP,
+	}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return err
+	}
+
+	aead, err := deriveAEAD(passphrase, params)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	params.Check = aead.Seal(nonce, nonce, keystoreCheck, nil)
+
+	if err := s.db.Create(&params).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.aead = aead
+	s.mu.Unlock()
+	return nil
+}
+
+func deriveAEAD(passphrase string, params models.KeystoreParams) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), params.Salt, params.ScryptN, params.ScryptR, params.ScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Lock forgets the master key.
+func (s *DBKeyStore) Lock() {
+	s.mu.Lock()
+	s.aead = nil
+	s.mu.Unlock()
+}
+
+func (s *DBKeyStore) Store(chain models.Chain, address, privateKey string) error {
+	s.mu.RLock()
+	aead := s.aead
+	s.mu.RUnlock()
+	if aead == nil {
+		return ErrLocked
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	return s.db.Create(&models.EncryptedKey{
+		Address:    address,
+		Chain:      chain,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, []byte(privateKey), []byte(address)),
+	}).Error
+}
+
+func (s *DBKeyStore) PrivateKey(address string) (string, error) {
+	s.mu.RLock()
+	aead := s.aead
+	s.mu.RUnlock()
+	if aead == nil {
+		return "", ErrLocked
+	}
+
+	var key models.EncryptedKey
+	err := s.db.First(&key, "address = ?", address).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := aead.Open(nil, key.Nonce, key.Ciphertext, []byte(address))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"multi-chain-payment-gateway/internal/models"
+	"testing"
+)
+
+// newTestKeyStore returns a keystore created with passphrase under cheap
+// scrypt parameters, which existing keystores keep.
+func newTestKeyStore(t *testing.T, passphrase string) *DBKeyStore {
+	t.Helper()
+	db := testDB(t)
+
+	params := models.KeystoreParams{Salt: make([]byte, 32), ScryptN: 1 << 10, ScryptR: 8, ScryptP: 1}
+	rand.Read(params.Salt)
+	aead, err := deriveAEAD(passphrase, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	params.Check = aead.Seal(nonce, nonce, keystoreCheck, nil)
+	if err := db.Create(&params).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewKeyStore(db)
+}
+
+func TestKeyStoreUnlock(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    error
+	}{
+		{"right passphrase", "correct horse", nil},
+		{"wrong passphrase", "battery staple", ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newTestKeyStore(t, "correct horse")
+			if err := keys.Unlock(tt.passphrase); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unlock() = %v, want %v", err, tt.wantErr)
+			}
+			if err := keys.Store(models.ChainEthereum, "0xa", "key"); (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Store() = %v after Unlock() = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyStoreRoundTrip(t *testing.T) {
+	keys := newTestKeyStore(t, "correct horse")
+	if _, err := keys.PrivateKey("0xa"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("PrivateKey() while locked = %v, want ErrLocked", err)
+	}
+	if err := keys.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := map[string]string{"0xa": "first key", "0xb": "second key"}
+	for address, key := range stored {
+		if err := keys.Store(models.ChainEthereum, address, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for address, want := range stored {
+		if got, err := keys.PrivateKey(address); err != nil || got != want {
+			t.Errorf("PrivateKey(%s) = %q, %v, want %q", address, got, err, want)
+		}
+	}
+	if _, err := keys.PrivateKey("0xc"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("PrivateKey() of an unknown address = %v, want ErrKeyNotFound", err)
+	}
+
+	// Keys are bound to their address
+	var sealed models.EncryptedKey
+	keys.db.First(&sealed, "address = ?", "0xb")
+	keys.db.Model(&models.EncryptedKey{}).Where("address = ?", "0xa").
+		Updates(map[string]interface{}{"nonce": sealed.Nonce, "ciphertext": sealed.Ciphertext})
+	if _, err := keys.PrivateKey("0xa"); err == nil {
+		t.Error("opened a key sealed for another address")
+	}
+
+	keys.Lock()
+	if _, err := keys.PrivateKey("0xb"); !errors.Is(err, ErrLocked) {
+		t.Errorf("PrivateKey() after Lock() = %v, want ErrLocked", err)
+	}
+}
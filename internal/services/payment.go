@@ -1,9 +1,11 @@
 package services
 
 import (
+	"multi-chain-payment-gateway/internal/chains"
 	"multi-chain-payment-gateway/internal/config"
 	"multi-chain-payment-gateway/internal/models"
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
@@ -16,9 +18,22 @@ type PaymentService struct {
 	db         *gorm.DB
 	priceService *PriceService
 	blockchainService *BlockchainService
+	webhookService *WebhookService
 	config     *config.Config
 }
 
+// confirmationInterval is how often detected transactions are re-checked,
+// roughly once per Ethereum block.
+const confirmationInterval = 12 * time.Second
+
+// ErrPaymentNotPending aborts recording a transaction for a payment that
+// has already left the pending state.
+var ErrPaymentNotPending = errors.New("payment is no longer pending")
+
+// monitoringInterval is how often chains that don't set their own poll
+// interval are checked for payments, and expired payments are marked.
+const monitoringInterval = 30 * time.Second
+
 type CreatePaymentRequest struct {
 	Amount     decimal.Decimal   `json:"amount" binding:"required"`
 	Currency   string           `json:"currency" binding:"required"`
@@ -27,11 +42,12 @@ type CreatePaymentRequest struct {
 	Metadata   map[string]interface{} `json:"metadata"`
 }
 
-func NewPaymentService(db *gorm.DB, priceService *PriceService, blockchainService *BlockchainService, config *config.Config) *PaymentService {
+func NewPaymentService(db *gorm.DB, priceService *PriceService, blockchainService *BlockchainService, webhookService *WebhookService, config *config.Config) *PaymentService {
 	return &PaymentService{
 		db:         db,
 		priceService: priceService,
 		blockchainService: blockchainService,
+		webhookService: webhookService,
 		config:     config,
 	}
 }
@@ -73,51 +89,76 @@ func (s *PaymentService) CreatePayment(req CreatePaymentRequest) (*models.Paymen
 	return payment, nil
 }
 
+// generatePaymentOptions offers every enabled token. The options of a
+// chain share one deposit address; adapters tell apart which token arrived.
 func (s *PaymentService) generatePaymentOptions(payment *models.Payment) error {
-	chains := []models.Chain{models.ChainEthereum, models.ChainSolana, models.ChainTON}
-	tokens := []models.TokenType{models.TokenNative, models.TokenUSDC, models.TokenUSDT}
+	wallets := make(map[models.Chain]*chains.Wallet)
+	for _, token := range s.blockchainService.Tokens().Enabled() {
+		// Skip tokens of networks that are no longer configured
+		if _, err := s.blockchainService.Registry().Get(token.Chain); err != nil {
+			continue
+		}
+
+		// Calculate amount in crypto, rounded up to what the token can
+		// represent so the quoted amount can be paid exactly
+		cryptoAmount, err := s.priceService.ConvertUSDToCrypto(payment.Amount, token.PriceSource)
+		if err != nil {
+			return err
+		}
+		cryptoAmount = cryptoAmount.RoundUp(int32(token.Decimals))
 
-	for _, chain := range chains {
-		for _, token := range tokens {
-			// Generate wallet for this chain
-			wallet, err := s.blockchainService.GenerateWallet(chain)
+		// Create payment option
+		option := &models.PaymentOption{
+			PaymentID: payment.ID,
+			Chain:     token.Chain,
+			Token:     token.Token,
+			Amount:    cryptoAmount,
+			Symbol:    token.Symbol,
+			Decimals:  token.Decimals,
+		}
+
+		if s.blockchainService.IssuesInvoices(token.Chain) {
+			// Bill the exact amount with an invoice, stored in place of an address
+			invoice, err := s.blockchainService.CreateInvoice(option, payment.ExpiresAt)
 			if err != nil {
 				return err
 			}
-
-			// Get token symbol and decimals
-			symbol := s.blockchainService.GetTokenSymbol(chain, token)
-			decimals := s.blockchainService.GetTokenDecimals(chain, token)
-
-			// Calculate amount in crypto
-			var cryptoAmount decimal.Decimal
-			if token == models.TokenNative {
-				// Convert USD to native token
-				var err error
-				cryptoAmount, err = s.priceService.ConvertUSDToCrypto(payment.Amount, symbol)
+			option.Address = invoice.Request
+			option.Reference = invoice.Reference
+		} else {
+			// Generate the chain's wallet with its first option
+			wallet, ok := wallets[token.Chain]
+			if !ok {
+				wallet, err = s.blockchainService.GenerateWallet(option)
+				if errors.Is(err, ErrAddressPoolExhausted) {
+					// Offer the other chains rather than failing the payment
+					log.Printf("Skipping %s for payment %s: %v", token.Chain, payment.ID, err)
+					wallets[token.Chain] = nil
+					continue
+				}
 				if err != nil {
 					return err
 				}
-			} else {
-				// For stablecoins, amount is 1:1 with USD
-				cryptoAmount = payment.Amount
+				wallets[token.Chain] = wallet
 			}
-
-			// Create payment option
-			option := &models.PaymentOption{
-				PaymentID: payment.ID,
-				Chain:     chain,
-				Token:     token,
-				Address:   wallet.Address,
-				Amount:    cryptoAmount,
-				Symbol:    symbol,
-				Decimals:  decimals,
+			if wallet == nil {
+				continue
 			}
+			option.Address = wallet.Address
+			option.DerivationIndex = wallet.DerivationIndex
+			option.DerivationPath = wallet.DerivationPath
+			option.ForwarderSalt = wallet.Salt
+			option.Reference = wallet.Reference
 
-			if err := s.db.Create(option).Error; err != nil {
+			option.PaymentURI, err = s.blockchainService.PaymentURI(option)
+			if err != nil {
 				return err
 			}
 		}
+
+		if err := s.db.Create(option).Error; err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -132,29 +173,66 @@ func (s *PaymentService) GetPayment(paymentID string) (*models.Payment, error) {
 	return &payment, nil
 }
 
+// StartMonitoring runs a detection loop per chain and periodically marks
+// expired payments.
 func (s *PaymentService) StartMonitoring() {
-	ticker := time.NewTicker(30 * time.Second)
+	for _, adapter := range s.blockchainService.Registry().Adapters() {
+		go s.monitorChain(adapter.Chain())
+	}
+
+	ticker := time.NewTicker(monitoringInterval)
 	defer ticker.Stop()
 
-	for {
-		select {
-		case <-ticker.C:
-			s.checkPendingPayments()
-		}
+	for range ticker.C {
+		s.markExpiredPayments()
+	}
+}
+
+// monitorChain checks the pending payments' options on chain at the
+// chain's own pace.
+func (s *PaymentService) monitorChain(chain models.Chain) {
+	ticker := time.NewTicker(s.blockchainService.PollInterval(chain, monitoringInterval))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.checkPendingPayments(chain)
 	}
 }
 
-func (s *PaymentService) checkPendingPayments() {
+func (s *PaymentService) checkPendingPayments(chain models.Chain) {
 	var payments []models.Payment
-	err := s.db.Preload("Options").Where("status = ? AND expires_at > ?", models.StatusPending, time.Now()).Find(&payments).Error
+	err := s.db.Preload("Options", "chain = ?", chain).Where("status = ? AND expires_at > ?", models.StatusPending, time.Now()).Find(&payments).Error
 	if err != nil {
 		log.Printf("Error fetching pending payments: %v", err)
 		return
 	}
 
+	// Let the chain's scanner catch up on the deposits we are waiting on
+	deposits := make(map[uint]chains.Deposit)
+	var watched []chains.Deposit
 	for _, payment := range payments {
 		for _, option := range payment.Options {
-			tx, err := s.blockchainService.CheckTransaction(option.Chain, option.Address, option.Amount)
+			deposit, err := s.blockchainService.Deposit(option)
+			if err != nil {
+				log.Printf("Error preparing option %d of payment %s: %v", option.ID, payment.ID, err)
+				continue
+			}
+			deposits[option.ID] = deposit
+			watched = append(watched, deposit)
+		}
+	}
+	if err := s.blockchainService.Sync(chain, watched); err != nil {
+		log.Printf("Error syncing %s: %v", chain, err)
+	}
+
+	for _, payment := range payments {
+		for _, option := range payment.Options {
+			deposit, ok := deposits[option.ID]
+			if !ok {
+				continue
+			}
+
+			tx, err := s.blockchainService.CheckTransaction(option.Chain, deposit)
 			if err != nil {
 				log.Printf("Error checking transaction for payment %s: %v", payment.ID, err)
 				continue
@@ -167,21 +245,148 @@ func (s *PaymentService) checkPendingPayments() {
 			}
 		}
 	}
-
-	// Mark expired payments
-	s.markExpiredPayments()
 }
 
 func (s *PaymentService) processPayment(payment *models.Payment, tx *models.Transaction) {
-	// Update payment status
-	payment.Status = models.StatusPaid
-	s.db.Save(payment)
+	// A rescan after a reorg can report a transaction we already recorded
+	var existing int64
+	s.db.Model(&models.Transaction{}).Where("tx_hash = ?", tx.TxHash).Count(&existing)
+	if existing > 0 {
+		return
+	}
 
-	// Save transaction
+	// Save transaction. Chains are monitored concurrently, so the payment
+	// is only claimed if no other chain's loop detected it first.
 	tx.PaymentID = payment.ID
-	s.db.Create(tx)
+	err := s.db.Transaction(func(db *gorm.DB) error {
+		result := db.Model(&models.Payment{}).
+			Where("id = ? AND status = ?", payment.ID, models.StatusPending).
+			Update("status", models.StatusDetected)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPaymentNotPending
+		}
+		return db.Create(tx).Error
+	})
+	if errors.Is(err, ErrPaymentNotPending) {
+		log.Printf("Ignoring transaction %s: payment %s is no longer pending", tx.TxHash, payment.ID)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving transaction %s for payment %s: %v", tx.TxHash, payment.ID, err)
+		return
+	}
+
+	// Wait for confirmations before marking the payment paid
+	payment.Status = models.StatusDetected
+
+	log.Printf("Payment %s detected with transaction %s", payment.ID, tx.TxHash)
+	s.notify(payment, EventPaymentDetected)
+}
+
+// StartConfirmationTracking periodically re-checks detected transactions
+// until they are final or dropped from the chain.
+func (s *PaymentService) StartConfirmationTracking() {
+	ticker := time.NewTicker(confirmationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.trackConfirmations()
+	}
+}
+
+func (s *PaymentService) trackConfirmations() {
+	var txs []models.Transaction
+	err := s.db.Joins("JOIN payments ON payments.id = transactions.payment_id").
+		Where("transactions.confirmed = ? AND payments.status = ?", false, models.StatusDetected).
+		Find(&txs).Error
+	if err != nil {
+		log.Printf("Error fetching unconfirmed transactions: %v", err)
+		return
+	}
+
+	for i := range txs {
+		tx := &txs[i]
+
+		var payment models.Payment
+		if err := s.db.First(&payment, "id = ?", tx.PaymentID).Error; err != nil {
+			log.Printf("Error loading payment %s: %v", tx.PaymentID, err)
+			continue
+		}
+
+		confirmations, err := s.blockchainService.GetConfirmations(tx)
+		if errors.Is(err, chains.ErrTransactionDropped) {
+			s.revertPayment(&payment, tx)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error checking confirmations for transaction %s: %v", tx.TxHash, err)
+			continue
+		}
+
+		tx.Confirmations = confirmations
+		tx.Confirmed = confirmations >= s.blockchainService.RequiredConfirmations(tx.Chain)
+		if err := s.db.Save(tx).Error; err != nil {
+			log.Printf("Error updating transaction %s: %v", tx.TxHash, err)
+			continue
+		}
 
-	log.Printf("Payment %s completed with transaction %s", payment.ID, tx.TxHash)
+		if tx.Confirmed {
+			payment.Status = models.StatusPaid
+			s.db.Model(&payment).Update("status", payment.Status)
+
+			log.Printf("Payment %s completed with transaction %s", payment.ID, tx.TxHash)
+			s.notify(&payment, EventPaymentCompleted)
+		}
+	}
+}
+
+// revertPayment puts a payment back to pending after its transaction was
+// dropped, so the transfer can be detected again if it is re-mined. A
+// payment past its expiry is no longer watched, so it is expired instead.
+func (s *PaymentService) revertPayment(payment *models.Payment, tx *models.Transaction) {
+	status := models.StatusPending
+	if !payment.ExpiresAt.After(time.Now()) {
+		status = models.StatusExpired
+	}
+
+	err := s.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Delete(tx).Error; err != nil {
+			return err
+		}
+		return db.Model(&models.Payment{}).Where("id = ?", payment.ID).Update("status", status).Error
+	})
+	if err != nil {
+		log.Printf("Error reverting payment %s after transaction %s was dropped: %v", payment.ID, tx.TxHash, err)
+		return
+	}
+	payment.Status = status
+
+	log.Printf("Transaction %s for payment %s was dropped by a reorg, payment is %s", tx.TxHash, payment.ID, status)
+	s.notify(payment, EventPaymentReorged)
+}
+
+// notify sends a webhook for a payment event in the background.
+func (s *PaymentService) notify(payment *models.Payment, event string) {
+	var metadata map[string]interface{}
+	json.Unmarshal([]byte(payment.Metadata), &metadata)
+
+	payload := WebhookPayload{
+		Event:     event,
+		PaymentID: payment.ID,
+		Status:    string(payment.Status),
+		Amount:    payment.Amount.String(),
+		Currency:  payment.Currency,
+		Metadata:  metadata,
+	}
+
+	go func() {
+		if err := s.webhookService.SendWebhook(payment.WebhookURL, payload); err != nil {
+			log.Printf("Error sending %s webhook for payment %s: %v", event, payment.ID, err)
+		}
+	}()
 }
 
 func (s *PaymentService) markExpiredPayments() {
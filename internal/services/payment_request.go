@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/models"
+	"time"
+)
+
+// ErrTransactionRequestUnavailable is returned for payment options whose
+// chain cannot build payment transactions for wallets.
+var ErrTransactionRequestUnavailable = errors.New("transaction requests are not available for this option")
+
+// TransactionRequestLabel is what a wallet shows before requesting a
+// payment transaction.
+type TransactionRequestLabel struct {
+	Label string `json:"label"`
+	Icon  string `json:"icon"`
+}
+
+// paymentRequest describes the transfer that pays option in full.
+func (s *BlockchainService) paymentRequest(option *models.PaymentOption) (chains.ChainAdapter, chains.PaymentRequest, error) {
+	adapter, err := s.registry.Get(option.Chain)
+	if err != nil {
+		return nil, chains.PaymentRequest{}, err
+	}
+	token, ok := chains.FindToken(adapter, option.Token)
+	if !ok {
+		return nil, chains.PaymentRequest{}, fmt.Errorf("unsupported token %s on %s", option.Token, option.Chain)
+	}
+
+	return adapter, chains.PaymentRequest{
+		Address:   option.Address,
+		Token:     token,
+		Amount:    option.Amount,
+		Reference: option.Reference,
+		Label:     s.config.MerchantName,
+		Memo:      option.PaymentID,
+	}, nil
+}
+
+// PaymentURI returns the wallet payment request for option, or "" if its
+// chain has no payment request scheme.
+func (s *BlockchainService) PaymentURI(option *models.PaymentOption) (string, error) {
+	adapter, req, err := s.paymentRequest(option)
+	if err != nil {
+		return "", err
+	}
+	requester, ok := adapter.(chains.PaymentRequester)
+	if !ok {
+		return "", nil
+	}
+	return requester.PaymentURI(req)
+}
+
+// TransactionRequestLabel returns the merchant's label and icon.
+func (s *PaymentService) TransactionRequestLabel() TransactionRequestLabel {
+	return TransactionRequestLabel{
+		Label: s.config.MerchantName,
+		Icon:  s.config.MerchantIconURL,
+	}
+}
+
+// PaymentTransaction builds the unsigned transaction with which account
+// pays an option, for the account's wallet to sign and submit. The payment
+// is then detected like any other transfer.
+func (s *PaymentService) PaymentTransaction(paymentID string, optionID uint, account string) ([]byte, error) {
+	_, option, err := s.payableOption(paymentID, optionID)
+	if err != nil {
+		return nil, err
+	}
+	adapter, req, err := s.blockchainService.paymentRequest(option)
+	if err != nil {
+		return nil, err
+	}
+	requester, ok := adapter.(chains.TransactionRequester)
+	if !ok {
+		return nil, ErrTransactionRequestUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return requester.PaymentTransaction(ctx, req, account)
+}
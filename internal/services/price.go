@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"multi-chain-payment-gateway/internal/models"
 	"net/http"
 	"time"
 
@@ -39,20 +40,21 @@ func NewPriceService(apiKey string) *PriceService {
 	}
 }
 
-func (s *PriceService) GetPrice(symbol string) (decimal.Decimal, error) {
+// GetPrice returns the USD price of a CoinGecko coin.
+func (s *PriceService) GetPrice(coinId string) (decimal.Decimal, error) {
 	// Check cache first
-	if cached, exists := s.cache[symbol]; exists && time.Now().Before(cached.ExpiresAt) {
+	if cached, exists := s.cache[coinId]; exists && time.Now().Before(cached.ExpiresAt) {
 		return cached.Price, nil
 	}
 
 	// Fetch from API
-	price, err := s.fetchPrice(symbol)
+	price, err := s.fetchPrice(coinId)
 	if err != nil {
 		return decimal.Zero, err
 	}
 
 	// Cache for 1 minute
-	s.cache[symbol] = CachedPrice{
+	s.cache[coinId] = CachedPrice{
 		Price:     price,
 		ExpiresAt: time.Now().Add(1 * time.Minute),
 	}
@@ -60,19 +62,7 @@ func (s *PriceService) GetPrice(symbol string) (decimal.Decimal, error) {
 	return price, nil
 }
 
-func (s *PriceService) fetchPrice(symbol string) (decimal.Decimal, error) {
-	var coinId string
-	switch symbol {
-	case "ETH":
-		coinId = "ethereum"
-	case "SOL":
-		coinId = "solana"
-	case "TON":
-		coinId = "the-open-network"
-	default:
-		return decimal.Zero, fmt.Errorf("unsupported symbol: %s", symbol)
-	}
-
+func (s *PriceService) fetchPrice(coinId string) (decimal.Decimal, error) {
 	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", coinId)
 	
 	req, err := http.NewRequest("GET", url, nil)
@@ -107,17 +97,23 @@ func (s *PriceService) fetchPrice(symbol string) (decimal.Decimal, error) {
 		}
 	}
 
-	return decimal.Zero, fmt.Errorf("price not found for %s", symbol)
+	return decimal.Zero, fmt.Errorf("price not found for %s", coinId)
 }
 
-func (s *PriceService) ConvertUSDToCrypto(usdAmount decimal.Decimal, symbol string) (decimal.Decimal, error) {
-	price, err := s.GetPrice(symbol)
+// ConvertUSDToCrypto converts a USD amount into a token priced by
+// priceSource, a CoinGecko coin ID or models.PriceSourceUSD.
+func (s *PriceService) ConvertUSDToCrypto(usdAmount decimal.Decimal, priceSource string) (decimal.Decimal, error) {
+	if priceSource == models.PriceSourceUSD {
+		return usdAmount, nil
+	}
+
+	price, err := s.GetPrice(priceSource)
 	if err != nil {
 		return decimal.Zero, err
 	}
 
 	if price.IsZero() {
-		return decimal.Zero, fmt.Errorf("invalid price for %s", symbol)
+		return decimal.Zero, fmt.Errorf("invalid price for %s", priceSource)
 	}
 
 	return usdAmount.Div(price), nil
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/config"
+	"multi-chain-payment-gateway/internal/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const sweepInterval = 5 * time.Minute
+
+// Sweeper moves confirmed payments from their deposit addresses to the
+// chain's treasury. Token deposits on chains that need the sender to hold
+// gas are topped up from the chain's gas wallet first. Only addresses
+// whose keys are in the keystore can be swept, apart from forwarder
+// contracts, which are flushed in batches paid for by the gas wallet.
+type Sweeper struct {
+	db         *gorm.DB
+	blockchain *BlockchainService
+	keys       KeyStore
+	treasuries map[models.Chain]string
+	gasKeys    map[models.Chain]string
+	offline    map[uint]bool // deposits reported as needing an offline sweep
+}
+
+func NewSweeper(db *gorm.DB, blockchain *BlockchainService, keys KeyStore, cfg *config.Config) (*Sweeper, error) {
+	s := &Sweeper{
+		db:         db,
+		blockchain: blockchain,
+		keys:       keys,
+		treasuries: make(map[models.Chain]string),
+		gasKeys:    make(map[models.Chain]string),
+		offline:    make(map[uint]bool),
+	}
+
+	for name, address := range cfg.Treasuries {
+		chain := models.Chain(name)
+		if _, err := s.sender(chain); err != nil {
+			return nil, err
+		}
+		if err := blockchain.ValidateAddress(chain, address); err != nil {
+			return nil, fmt.Errorf("invalid %s treasury: %w", chain, err)
+		}
+		s.treasuries[chain] = address
+	}
+
+	for name, key := range cfg.GasWalletKeys {
+		chain := models.Chain(name)
+		sender, err := s.sender(chain)
+		if err != nil {
+			return nil, err
+		}
+		address, err := sender.AddressOf(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s gas wallet key: %w", chain, err)
+		}
+		log.Printf("Paying %s sweep fees from %s", chain, address)
+		s.gasKeys[chain] = key
+	}
+
+	return s, nil
+}
+
+// sender returns the adapter of chain, which must be able to send funds.
+func (s *Sweeper) sender(chain models.Chain) (chains.Sender, error) {
+	adapter, err := s.blockchain.Registry().Get(chain)
+	if err != nil {
+		return nil, err
+	}
+	sender, ok := adapter.(chains.Sender)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support sending", chain)
+	}
+	return sender, nil
+}
+
+// Start sweeps periodically. It returns right away in watch-only mode or
+// if no treasury is configured.
+func (s *Sweeper) Start() {
+	if err := s.blockchain.RequireCustody(); err != nil {
+		log.Printf("Sweeper disabled: %v", err)
+		return
+	}
+	if len(s.treasuries) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.sweep()
+	}
+}
+
+func (s *Sweeper) sweep() {
+	s.followFlushes()
+
+	var txs []models.Transaction
+	err := s.db.Joins("JOIN payments ON payments.id = transactions.payment_id").
+		Where("transactions.confirmed = ? AND transactions.swept = ? AND transactions.sweep_id IS NULL AND payments.status = ?", true, false, models.StatusPaid).
+		Find(&txs).Error
+	if err != nil {
+		log.Printf("Error fetching transactions to sweep: %v", err)
+		return
+	}
+
+	// Forwarder deposits are flushed together, per chain and token
+	flushes := make(map[flushKey][]forwarderDeposit)
+	for i := range txs {
+		tx := &txs[i]
+		salt, err := s.forwarderSalt(tx)
+		if err != nil {
+			log.Printf("Error sweeping %s deposit %s: %v", tx.Chain, tx.ToAddress, err)
+			continue
+		}
+		if salt != "" {
+			key := flushKey{chain: tx.Chain, token: tx.Token}
+			flushes[key] = append(flushes[key], forwarderDeposit{tx: tx, salt: salt})
+			continue
+		}
+
+		if err := s.sweepTransaction(tx); err != nil {
+			log.Printf("Error sweeping %s deposit %s: %v", tx.Chain, tx.ToAddress, err)
+		}
+	}
+
+	for key, deposits := range flushes {
+		if err := s.flush(key, deposits); err != nil {
+			log.Printf("Error flushing %d %s %s forwarders: %v", len(deposits), key.chain, key.token, err)
+		}
+	}
+}
+
+// sweepTransaction advances the sweep of one deposit by a step: it follows
+// up on the last transfer sent for it, or sends the gas top-up or the
+// sweep itself.
+func (s *Sweeper) sweepTransaction(tx *models.Transaction) error {
+	treasury := s.treasuries[tx.Chain]
+	if treasury == "" {
+		return nil
+	}
+	adapter, err := s.blockchain.Registry().Get(tx.Chain)
+	if err != nil {
+		return err
+	}
+	sender, ok := adapter.(chains.Sender)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	toppedUp := false
+	var last models.OutgoingTransaction
+	err = s.db.Where("transaction_id = ?", tx.ID).Order("id DESC").First(&last).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == nil && last.Status == models.OutgoingSubmitted {
+		status, err := s.transferStatus(ctx, sender, &last)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case chains.TransferPending:
+			return nil
+		case chains.TransferFailed:
+			if last.Error == "" {
+				last.Error = "transaction failed on chain"
+			}
+			last.Status = models.OutgoingFailed
+			return s.db.Save(&last).Error
+		}
+		last.Status = models.OutgoingConfirmed
+		if err := s.db.Save(&last).Error; err != nil {
+			return err
+		}
+		if last.Kind == models.OutgoingSweep {
+			return s.markSwept(tx)
+		}
+		// The gas top-up went through; sweep right away
+		toppedUp = true
+	}
+
+	key, err := s.keys.PrivateKey(tx.ToAddress)
+	if errors.Is(err, ErrKeyNotFound) {
+		// Derived from an xpub; the keys are held offline
+		if !s.offline[tx.ID] {
+			s.offline[tx.ID] = true
+			log.Printf("Payment %s on %s needs an offline sweep: the key of %s is not in the keystore", tx.PaymentID, tx.Chain, tx.ToAddress)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, ok := chains.FindToken(adapter, tx.Token)
+	if !ok {
+		return fmt.Errorf("unsupported token %s", tx.Token)
+	}
+	native, _ := chains.FindToken(adapter, models.TokenNative)
+
+	balance, err := sender.Balance(ctx, tx.ToAddress, token)
+	if err != nil {
+		return err
+	}
+	if balance.Sign() == 0 {
+		return s.markSwept(tx)
+	}
+
+	// Chains that let the gas wallet pay the fees need no top-up, but their
+	// token sweeps rely on it
+	gasKey := s.gasKeys[tx.Chain]
+	sponsor, ok := adapter.(chains.FeeSponsor)
+	canSponsor := ok && sponsor.SponsorsFees()
+	if canSponsor && token.Token != models.TokenNative && gasKey == "" {
+		return fmt.Errorf("no gas wallet configured to pay for sweeping %s", tx.ToAddress)
+	}
+	sponsored := canSponsor && gasKey != ""
+
+	amount := balance
+	if !sponsored {
+		fee, err := sender.TransferFee(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		if token.Token == models.TokenNative {
+			// The sweep pays its own fee out of the balance
+			amount = new(big.Int).Sub(balance, fee)
+			if amount.Sign() <= 0 {
+				return s.markSwept(tx)
+			}
+		} else if !toppedUp {
+			gas, err := sender.Balance(ctx, tx.ToAddress, native)
+			if err != nil {
+				return err
+			}
+			if gas.Cmp(fee) < 0 {
+				if gasKey == "" {
+					return fmt.Errorf("no gas wallet configured to top up %s", tx.ToAddress)
+				}
+				return s.send(ctx, sender, tx, models.OutgoingGasTopUp, chains.TransferRequest{
+					PrivateKey: gasKey,
+					To:         tx.ToAddress,
+					Token:      native,
+					Amount:     new(big.Int).Sub(fee, gas),
+				})
+			}
+		}
+	}
+
+	req := chains.TransferRequest{
+		PrivateKey: key,
+		To:         treasury,
+		Token:      token,
+		Amount:     amount,
+	}
+	if sponsored {
+		req.FeePayerKey = gasKey
+	}
+	return s.send(ctx, sender, tx, models.OutgoingSweep, req)
+}
+
+// send records an outgoing transfer, broadcasts it and tracks the result.
+func (s *Sweeper) send(ctx context.Context, sender chains.Sender, tx *models.Transaction, kind models.OutgoingKind, req chains.TransferRequest) error {
+	from, err := sender.AddressOf(req.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	outgoing := &models.OutgoingTransaction{
+		Kind:          kind,
+		Chain:         tx.Chain,
+		PaymentID:     tx.PaymentID,
+		TransactionID: tx.ID,
+		FromAddress:   from,
+		ToAddress:     req.To,
+		Token:         req.Token.Token,
+		TokenContract: req.Token.Contract,
+		Amount:        decimal.NewFromBigInt(req.Amount, -int32(req.Token.Decimals)),
+		Status:        models.OutgoingPending,
+	}
+	return s.submit(outgoing, req.Token.Symbol, func(record *models.OutgoingTransaction) (string, error) {
+		req.Record = record
+		return sender.Transfer(ctx, req)
+	})
+}
+
+// submit records outgoing as pending, broadcasts it and marks it submitted
+// or failed.
+func (s *Sweeper) submit(outgoing *models.OutgoingTransaction, symbol string, broadcast func(*models.OutgoingTransaction) (string, error)) error {
+	if err := s.db.Create(outgoing).Error; err != nil {
+		return err
+	}
+
+	hash, err := broadcast(outgoing)
+	if err != nil {
+		s.db.Model(outgoing).Updates(map[string]interface{}{"status": models.OutgoingFailed, "error": err.Error()})
+		return err
+	}
+
+	log.Printf("Sent %s of %s %s from %s to %s: %s", outgoing.Kind, outgoing.Amount, symbol, outgoing.FromAddress, outgoing.ToAddress, hash)
+	outgoing.TxHash = hash
+	outgoing.Status = models.OutgoingSubmitted
+	return s.db.Save(outgoing).Error
+}
+
+// transferStatus checks on a submitted transfer. Senders that track their
+// transfers may replace it, so the record is saved even while pending.
+func (s *Sweeper) transferStatus(ctx context.Context, sender chains.Sender, outgoing *models.OutgoingTransaction) (chains.TransferStatus, error) {
+	tracker, ok := sender.(chains.TransferTracker)
+	if !ok {
+		return sender.TransferStatus(ctx, outgoing.TxHash)
+	}
+
+	var key string
+	var err error
+	if outgoing.Kind != models.OutgoingSweep {
+		// Top-ups and flushes are sent from the gas wallet
+		key = s.gasKeys[outgoing.Chain]
+	} else {
+		key, err = s.keys.PrivateKey(outgoing.FromAddress)
+		if err != nil {
+			return chains.TransferPending, err
+		}
+	}
+
+	status, err := tracker.TrackTransfer(ctx, outgoing, key)
+	if status == chains.TransferPending {
+		if saveErr := s.db.Save(outgoing).Error; saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return status, err
+}
+
+func (s *Sweeper) markSwept(tx *models.Transaction) error {
+	return s.db.Model(tx).Update("swept", true).Error
+}
+
+type flushKey struct {
+	chain models.Chain
+	token models.TokenType
+}
+
+type forwarderDeposit struct {
+	tx   *models.Transaction
+	salt string
+}
+
+// forwarderSalt returns the CREATE2 salt of the forwarder tx was paid to,
+// or "" if it went to an ordinary address.
+func (s *Sweeper) forwarderSalt(tx *models.Transaction) (string, error) {
+	var salts []string
+	err := s.db.Model(&models.PaymentOption{}).
+		Where("payment_id = ? AND chain = ? AND address = ? AND forwarder_salt <> ''", tx.PaymentID, tx.Chain, tx.ToAddress).
+		Limit(1).
+		Pluck("forwarder_salt", &salts).Error
+	if err != nil || len(salts) == 0 {
+		return "", err
+	}
+	return salts[0], nil
+}
+
+// flush sweeps forwarder deposits of one token with a single factory call
+// paid by the gas wallet. The deposits are linked to the flush until it
+// confirms or fails.
+func (s *Sweeper) flush(key flushKey, deposits []forwarderDeposit) error {
+	treasury := s.treasuries[key.chain]
+	if treasury == "" {
+		return nil
+	}
+	adapter, err := s.blockchain.Registry().Get(key.chain)
+	if err != nil {
+		return err
+	}
+	flusher, ok := adapter.(chains.ForwarderFlusher)
+	sender, isSender := adapter.(chains.Sender)
+	if !ok || !isSender {
+		return fmt.Errorf("%s does not support forwarders", key.chain)
+	}
+	gasKey := s.gasKeys[key.chain]
+	if gasKey == "" {
+		return fmt.Errorf("no gas wallet configured to pay for flushing %s forwarders", key.chain)
+	}
+	token, ok := chains.FindToken(adapter, key.token)
+	if !ok {
+		return fmt.Errorf("unsupported token %s", key.token)
+	}
+	from, err := sender.AddressOf(gasKey)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	salts := make([]string, 0, len(deposits))
+	ids := make([]uint, 0, len(deposits))
+	amount := decimal.Zero
+	for _, deposit := range deposits {
+		salts = append(salts, deposit.salt)
+		ids = append(ids, deposit.tx.ID)
+		amount = amount.Add(deposit.tx.Amount)
+	}
+
+	outgoing := &models.OutgoingTransaction{
+		Kind:          models.OutgoingFlush,
+		Chain:         key.chain,
+		FromAddress:   from,
+		ToAddress:     treasury,
+		Token:         token.Token,
+		TokenContract: token.Contract,
+		Amount:        amount,
+		Status:        models.OutgoingPending,
+	}
+	err = s.submit(outgoing, token.Symbol, func(record *models.OutgoingTransaction) (string, error) {
+		return flusher.FlushForwarders(ctx, gasKey, token, salts, record)
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&models.Transaction{}).Where("id IN ?", ids).Update("sweep_id", outgoing.ID).Error
+}
+
+// followFlushes checks on submitted flushes. Deposits of confirmed ones are
+// swept; those of failed ones are released to be flushed again.
+func (s *Sweeper) followFlushes() {
+	var flushes []models.OutgoingTransaction
+	err := s.db.Where("kind = ? AND status = ?", models.OutgoingFlush, models.OutgoingSubmitted).Find(&flushes).Error
+	if err != nil {
+		log.Printf("Error fetching forwarder flushes: %v", err)
+		return
+	}
+
+	for i := range flushes {
+		if err := s.followFlush(&flushes[i]); err != nil {
+			log.Printf("Error checking %s forwarder flush %s: %v", flushes[i].Chain, flushes[i].TxHash, err)
+		}
+	}
+}
+
+func (s *Sweeper) followFlush(flush *models.OutgoingTransaction) error {
+	adapter, err := s.blockchain.Registry().Get(flush.Chain)
+	if err != nil {
+		return err
+	}
+	sender, ok := adapter.(chains.Sender)
+	if !ok {
+		return fmt.Errorf("%s does not support sending", flush.Chain)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	status, err := s.transferStatus(ctx, sender, flush)
+	if err != nil || status == chains.TransferPending {
+		return err
+	}
+
+	deposits := s.db.Model(&models.Transaction{}).Where("sweep_id = ?", flush.ID)
+	if status == chains.TransferFailed {
+		if flush.Error == "" {
+			flush.Error = "transaction failed on chain"
+		}
+		flush.Status = models.OutgoingFailed
+		if err := s.db.Save(flush).Error; err != nil {
+			return err
+		}
+		return deposits.Update("sweep_id", nil).Error
+	}
+
+	flush.Status = models.OutgoingConfirmed
+	if err := s.db.Save(flush).Error; err != nil {
+		return err
+	}
+	return deposits.Update("swept", true).Error
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/config"
+	"multi-chain-payment-gateway/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TokenRegistry holds the tokens accepted on each chain, in the order
+// payment options are offered.
+type TokenRegistry struct {
+	tokens []models.Token
+}
+
+// defaultTokens lists the tokens offered out of the box. Stablecoins are
+// only enabled once their contract is configured.
+func defaultTokens(cfg *config.Config) []models.Token {
+	var tokens []models.Token
+	for _, network := range cfg.EVMNetworks {
+		chain := models.Chain(network.Chain)
+		tokens = append(tokens,
+			models.Token{Chain: chain, Token: models.TokenNative, Symbol: network.NativeSymbol, Decimals: 18, PriceSource: network.NativePriceID},
+			models.Token{Chain: chain, Token: models.TokenUSDC, Symbol: "USDC", Decimals: network.StableDecimals, Contract: network.USDC, PriceSource: models.PriceSourceUSD},
+			models.Token{Chain: chain, Token: models.TokenUSDT, Symbol: "USDT", Decimals: network.StableDecimals, Contract: network.USDT, PriceSource: models.PriceSourceUSD},
+		)
+	}
+
+	return append(tokens, []models.Token{
+		{Chain: models.ChainSolana, Token: models.TokenNative, Symbol: "SOL", Decimals: 9, PriceSource: "solana"},
+		{Chain: models.ChainSolana, Token: models.TokenUSDC, Symbol: "USDC", Decimals: 6, Contract: cfg.SolanaUSDC, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainSolana, Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6, Contract: cfg.SolanaUSDT, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainTON, Token: models.TokenNative, Symbol: "TON", Decimals: 9, PriceSource: "the-open-network"},
+		{Chain: models.ChainTON, Token: models.TokenUSDC, Symbol: "USDC", Decimals: 6, Contract: cfg.TonUSDC, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainTON, Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6, Contract: cfg.TonUSDT, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainBitcoin, Token: models.TokenNative, Symbol: "BTC", Decimals: 8, PriceSource: "bitcoin"},
+		{Chain: models.ChainTron, Token: models.TokenNative, Symbol: "TRX", Decimals: 6, PriceSource: "tron"},
+		{Chain: models.ChainTron, Token: models.TokenUSDC, Symbol: "USDC", Decimals: 6, Contract: cfg.TronUSDC, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainTron, Token: models.TokenUSDT, Symbol: "USDT", Decimals: 6, Contract: cfg.TronUSDT, PriceSource: models.PriceSourceUSD},
+		{Chain: models.ChainLightning, Token: models.TokenNative, Symbol: "BTC", Decimals: 8, PriceSource: "bitcoin"},
+	}...)
+}
+
+// NewTokenRegistry seeds the tokens table with the configured defaults and
+// loads it. Rows already in the database take precedence over the config,
+// except that a contract configured later fills in and enables a token that
+// had none. Chain adapters are configured from the registry at startup, so
+// edits to the table take effect on restart.
+func NewTokenRegistry(db *gorm.DB, cfg *config.Config) (*TokenRegistry, error) {
+	r := &TokenRegistry{}
+
+	for _, def := range defaultTokens(cfg) {
+		def.Enabled = def.Token == models.TokenNative || def.Contract != ""
+
+		var token models.Token
+		err := db.Where(models.Token{Chain: def.Chain, Token: def.Token}).Attrs(def).FirstOrCreate(&token).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Contract == "" && def.Contract != "" {
+			err := db.Model(&token).Updates(map[string]interface{}{"contract": def.Contract, "enabled": true}).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := db.Order("id").Find(&r.tokens).Error; err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Enabled returns the tokens payment options are generated for.
+func (r *TokenRegistry) Enabled() []models.Token {
+	var enabled []models.Token
+	for _, token := range r.tokens {
+		if token.Enabled {
+			enabled = append(enabled, token)
+		}
+	}
+	return enabled
+}
+
+// Get returns the registry entry for token on chain.
+func (r *TokenRegistry) Get(chain models.Chain, token models.TokenType) (models.Token, bool) {
+	for _, t := range r.tokens {
+		if t.Chain == chain && t.Token == token {
+			return t, true
+		}
+	}
+	return models.Token{}, false
+}
+
+// ChainTokens returns the enabled tokens of chain in the form chain adapters
+// are configured with.
+func (r *TokenRegistry) ChainTokens(chain models.Chain) []chains.Token {
+	var tokens []chains.Token
+	for _, t := range r.Enabled() {
+		if t.Chain == chain {
+			tokens = append(tokens, chains.Token{
+				Token:    t.Token,
+				Symbol:   t.Symbol,
+				Decimals: t.Decimals,
+				Contract: t.Contract,
+			})
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"multi-chain-payment-gateway/internal/chains"
+	"multi-chain-payment-gateway/internal/config"
+	"multi-chain-payment-gateway/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWatchOnly is returned by operations that need private keys, such
+	// as sweeps and refunds, when the gateway runs in watch-only mode.
+	ErrWatchOnly = errors.New("not available in watch-only mode")
+
+	ErrAddressPoolExhausted = errors.New("all watch addresses are in use")
+)
+
+// reservationWindow covers the time between handing out a watch address
+// and saving the payment option that shows it in use.
+const reservationWindow = time.Minute
+
+// addressCooldown keeps the address of an unpaid payment out of the pool
+// after the payment expires, so a late transfer for it is not credited to
+// the next payment handed the same address.
+const addressCooldown = 24 * time.Hour
+
+// watchOnlyRegistry keeps the adapters that can hand out deposit addresses
+// without private keys: those deriving from an xpub, those issuing invoices
+// and those with a merchant-supplied address list. The others are dropped.
+// Public ed25519 roots are not supported: SLIP-0010 only derives ed25519
+// keys through hardened steps, which need the private key, so Solana and
+// TON need a merchant address or an address list.
+func watchOnlyRegistry(all *chains.Registry, cfg *config.Config) (*chains.Registry, error) {
+	registry := chains.NewRegistry()
+	for _, adapter := range all.Adapters() {
+		chain := adapter.Chain()
+
+		if addresses := cfg.WatchAddresses[string(chain)]; len(addresses) > 0 {
+			for _, address := range addresses {
+				if err := adapter.ValidateAddress(address); err != nil {
+					return nil, fmt.Errorf("invalid %s watch address: %w", chain, err)
+				}
+			}
+			registry.Register(adapter)
+			continue
+		}
+
+		if deriver, ok := adapter.(chains.PublicDeriver); ok && deriver.DerivesPublicly() {
+			registry.Register(adapter)
+			continue
+		}
+		if _, ok := adapter.(chains.InvoiceIssuer); ok {
+			registry.Register(adapter)
+			continue
+		}
+
+		log.Printf("Watch-only mode: disabling %s, which has no xpub or watch addresses configured", chain)
+	}
+	return registry, nil
+}
+
+// addressPool hands out merchant-supplied deposit addresses in watch-only
+// mode. Addresses are reused round-robin, skipping any still awaiting or
+// confirming a payment, or cooling down after an unpaid payment ended.
+type addressPool struct {
+	db        *gorm.DB
+	addresses map[models.Chain][]string
+
+	mu       sync.Mutex
+	next     map[models.Chain]int
+	reserved map[string]time.Time // address => when it was handed out
+}
+
+func newAddressPool(db *gorm.DB, lists map[string][]string) *addressPool {
+	addresses := make(map[models.Chain][]string, len(lists))
+	for chain, list := range lists {
+		addresses[models.Chain(chain)] = list
+	}
+
+	return &addressPool{
+		db:        db,
+		addresses: addresses,
+		next:      make(map[models.Chain]int),
+		reserved:  make(map[string]time.Time),
+	}
+}
+
+func (p *addressPool) has(chain models.Chain) bool {
+	return len(p.addresses[chain]) > 0
+}
+
+// take hands out a free address of chain.
+func (p *addressPool) take(chain models.Chain) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Addresses of open payments, including ones handed out before a
+	// restart, and of unpaid ones that ended within the cooldown
+	var inUse []string
+	err := p.db.Model(&models.PaymentOption{}).
+		Joins("JOIN payments ON payments.id = payment_options.payment_id").
+		Where("payment_options.chain = ? AND (payments.status = ? OR payments.status <> ? AND payments.expires_at > ?)",
+			chain, models.StatusDetected, models.StatusPaid, time.Now().Add(-addressCooldown)).
+		Pluck("payment_options.address", &inUse).Error
+	if err != nil {
+		return "", err
+	}
+	busy := make(map[string]bool, len(inUse))
+	for _, address := range inUse {
+		busy[address] = true
+	}
+
+	addresses := p.addresses[chain]
+	for i := 0; i < len(addresses); i++ {
+		n := (p.next[chain] + i) % len(addresses)
+		address := addresses[n]
+		if busy[address] || time.Since(p.reserved[address]) < reservationWindow {
+			continue
+		}
+
+		p.next[chain] = n + 1
+		p.reserved[address] = time.Now()
+		return address, nil
+	}
+	return "", ErrAddressPoolExhausted
+}
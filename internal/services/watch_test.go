@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"multi-chain-payment-gateway/internal/models"
+	"testing"
+	"time"
+)
+
+func TestAddressPool(t *testing.T) {
+	db := testDB(t)
+	payments := []struct {
+		address string
+		status  models.PaymentStatus
+		expires time.Duration
+	}{
+		{"open", models.StatusPending, time.Hour},
+		{"confirming", models.StatusDetected, -time.Hour},
+		{"paid", models.StatusPaid, -time.Hour},
+		{"expired", models.StatusExpired, -time.Hour},
+		{"cancelled", models.StatusCancelled, time.Hour},
+		{"cooled down", models.StatusExpired, -addressCooldown - time.Hour},
+	}
+	var addresses []string
+	for _, p := range payments {
+		payment := models.Payment{ID: p.address, Status: p.status, ExpiresAt: time.Now().Add(p.expires)}
+		if err := db.Create(&payment).Error; err != nil {
+			t.Fatal(err)
+		}
+		option := models.PaymentOption{PaymentID: payment.ID, Chain: models.ChainSolana, Address: p.address}
+		if err := db.Create(&option).Error; err != nil {
+			t.Fatal(err)
+		}
+		addresses = append(addresses, p.address)
+	}
+
+	pool := newAddressPool(db, map[string][]string{string(models.ChainSolana): addresses})
+	for _, want := range []string{"paid", "cooled down"} {
+		got, err := pool.take(models.ChainSolana)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("take() = %q, want %q", got, want)
+		}
+	}
+
+	// Both free addresses are reserved until their options are saved
+	if got, err := pool.take(models.ChainSolana); !errors.Is(err, ErrAddressPoolExhausted) {
+		t.Errorf("take() = %q, %v, want %v", got, err, ErrAddressPoolExhausted)
+	}
+}
@@ -11,6 +11,13 @@ import (
 	"time"
 )
 
+// Webhook events sent over a payment's lifetime
+const (
+	EventPaymentDetected  = "payment.detected"
+	EventPaymentCompleted = "payment.completed"
+	EventPaymentReorged   = "payment.reorged"
+)
+
 type WebhookService struct {
 	secret string
 }
@@ -28,12 +28,33 @@ func main() {
 
 	// Initialize services
 	priceService := services.NewPriceService(cfg.PriceAPIKey)
-	blockchainService := services.NewBlockchainService(cfg)
-	paymentService := services.NewPaymentService(db, priceService, blockchainService, cfg)
+	tokenRegistry, err := services.NewTokenRegistry(db, cfg)
+	if err != nil {
+		log.Fatal("Failed to load token registry:", err)
+	}
+	// In watch-only mode the keystore stays locked, so no key can be stored
+	keyStore := services.NewKeyStore(db)
+	if !cfg.WatchOnly {
+		if err := keyStore.Unlock(keystorePassphrase(cfg)); err != nil {
+			log.Fatal("Failed to unlock keystore:", err)
+		}
+	}
+	blockchainService, err := services.NewBlockchainService(cfg, db, tokenRegistry, keyStore)
+	if err != nil {
+		log.Fatal("Failed to initialize blockchain service:", err)
+	}
 	webhookService := services.NewWebhookService(cfg.WebhookSecret)
+	paymentService := services.NewPaymentService(db, priceService, blockchainService, webhookService, cfg)
+
+	sweeper, err := services.NewSweeper(db, blockchainService, keyStore, cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize sweeper:", err)
+	}
 
 	// Start blockchain monitoring
 	go paymentService.StartMonitoring()
+	go paymentService.StartConfirmationTracking()
+	go sweeper.Start()
 
 	// Initialize API server
 	router := api.NewRouter(paymentService, webhookService, cfg)
@@ -48,4 +69,19 @@ func main() {
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
+}
+
+// keystorePassphrase returns the configured keystore passphrase. A
+// passphrase is required unless ENVIRONMENT is explicitly set to
+// development; the configuration's development default does not count, so
+// a deployment that forgets both never seals keys under a known passphrase.
+func keystorePassphrase(cfg *config.Config) string {
+	if cfg.KeystorePass != "" {
+		return cfg.KeystorePass
+	}
+	if os.Getenv("ENVIRONMENT") != "development" {
+		log.Fatal("KEYSTORE_PASSPHRASE must be set unless ENVIRONMENT=development")
+	}
+	log.Println("KEYSTORE_PASSPHRASE is not set, using the insecure development passphrase")
+	return "development"
 }
\ No newline at end of file
@@ -55,8 +55,16 @@
                     font-weight: 500;
                 }
                 .chain-ethereum { background: #dbeafe; color: #1e40af; }
+                .chain-polygon { background: #ede9fe; color: #6d28d9; }
+                .chain-arbitrum { background: #e0f2fe; color: #0369a1; }
+                .chain-base { background: #dbeafe; color: #1d4ed8; }
+                .chain-bsc { background: #fef9c3; color: #a16207; }
+                .chain-optimism { background: #fee2e2; color: #b91c1c; }
                 .chain-solana { background: #e9d5ff; color: #7c3aed; }
                 .chain-ton { background: #d1fae5; color: #059669; }
+                .chain-bitcoin { background: #ffedd5; color: #c2410c; }
+                .chain-tron { background: #fee2e2; color: #dc2626; }
+                .chain-lightning { background: #fef3c7; color: #b45309; }
             </style>
         `;
     }
@@ -93,8 +101,16 @@
         
         const chains = {
             'ethereum': 'Ethereum',
+            'polygon': 'Polygon',
+            'arbitrum': 'Arbitrum',
+            'base': 'Base',
+            'bsc': 'BNB Chain',
+            'optimism': 'Optimism',
             'solana': 'Solana', 
-            'ton': 'TON'
+            'ton': 'TON',
+            'bitcoin': 'Bitcoin',
+            'tron': 'Tron',
+            'lightning': 'Lightning'
         };
         
         let html = `
@@ -144,8 +160,16 @@
         
         const chains = {
             'ethereum': 'Ethereum',
+            'polygon': 'Polygon',
+            'arbitrum': 'Arbitrum',
+            'base': 'Base',
+            'bsc': 'BNB Chain',
+            'optimism': 'Optimism',
             'solana': 'Solana', 
-            'ton': 'TON'
+            'ton': 'TON',
+            'bitcoin': 'Bitcoin',
+            'tron': 'Tron',
+            'lightning': 'Lightning'
         };
         
         const chainName = chains[selectedOption.chain] || selectedOption.chain;
@@ -173,6 +197,19 @@
                     ${selectedOption.address}
                 </div>
             </div>
+            ${selectedOption.payment_uri ? `
+            <div style="text-align: center; margin-top: 12px;">
+                <a href="${selectedOption.payment_uri}" style="color: #3b82f6; font-size: 14px; text-decoration: none;">Open in wallet</a>
+            </div>
+            ` : ''}
+            ${selectedOption.chain === 'ton' && selectedOption.reference ? `
+            <div style="background: #f9fafb; border-radius: 8px; padding: 12px; margin-top: 8px;">
+                <div style="color: #6b7280; font-size: 12px; margin-bottom: 4px;">With comment (required):</div>
+                <div style="font-family: monospace; font-size: 14px; word-break: break-all; background: white; padding: 8px; border-radius: 4px; border: 1px solid #e5e7eb;">
+                    ${selectedOption.reference}
+                </div>
+            </div>
+            ` : ''}
             
             <div style="text-align: center; margin-top: 16px;">
                 <div style="color: #6b7280; font-size: 14px;">